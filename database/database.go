@@ -3,11 +3,51 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	// "log"
+	"micromiro/env"
 	"os"
+	"time"
+
 	_ "github.com/lib/pq"
 )
 
+// PoolConfig - настройки пула соединений с базой данных
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// LoadPoolConfig читает настройки пула из переменных окружения
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME и DB_CONN_MAX_IDLE_TIME.
+// Длительности задаются в формате Go, например "30m".
+func LoadPoolConfig() (PoolConfig, error) {
+	config := PoolConfig{
+		MaxOpenConns:    25,
+		MaxIdleConns:    25,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+
+	var err error
+	if config.MaxOpenConns, err = env.Int("DB_MAX_OPEN_CONNS", config.MaxOpenConns); err != nil {
+		return config, err
+	}
+	if config.MaxIdleConns, err = env.Int("DB_MAX_IDLE_CONNS", config.MaxIdleConns); err != nil {
+		return config, err
+	}
+	if config.ConnMaxLifetime, err = env.Duration("DB_CONN_MAX_LIFETIME", config.ConnMaxLifetime); err != nil {
+		return config, err
+	}
+	if config.ConnMaxIdleTime, err = env.Duration("DB_CONN_MAX_IDLE_TIME", config.ConnMaxIdleTime); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// ConnectDB открывает пул соединений, который используется всем приложением.
+// Вызывается один раз при старте. Схема создается миграциями, см. MigrateUp.
 func ConnectDB() (*sql.DB, error) {
 	host := os.Getenv("DB_HOST")
 	port := os.Getenv("DB_PORT")
@@ -19,6 +59,11 @@ func ConnectDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("missing database configuration")
 	}
 
+	pool, err := LoadPoolConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
@@ -27,11 +72,15 @@ func ConnectDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("error connecting to the database: %v", err)
 	}
 
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	err = db.Ping()
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("error pinging database: %v", err)
-	}else{
-		CreateTables(db)
 	}
 
 	return db, nil
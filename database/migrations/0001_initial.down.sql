@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.board_elements;
+DROP TABLE IF EXISTS public.board_permissions;
+DROP TABLE IF EXISTS public.boards;
+DROP TABLE IF EXISTS public.users;
+DROP TABLE IF EXISTS public.roles;
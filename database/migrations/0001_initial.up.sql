@@ -1,12 +1,13 @@
-package database
+-- Базовая схема. Написана идемпотентно, чтобы её можно было применить
+-- к базе, созданной до появления миграций.
 
-import (
-	"database/sql"
-)
+CREATE TABLE IF NOT EXISTS public.roles
+(
+    id serial NOT NULL,
+    name character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    CONSTRAINT roles_pkey PRIMARY KEY (id)
+);
 
-func CreateTables(db *sql.DB) error{
-	query := `
-	
 CREATE TABLE IF NOT EXISTS public.users
 (
     id serial NOT NULL,
@@ -21,13 +22,6 @@ CREATE TABLE IF NOT EXISTS public.users
     CONSTRAINT users_username_key UNIQUE (username)
 );
 
-CREATE TABLE IF NOT EXISTS public.roles
-(
-    id serial NOT NULL,
-    name character varying(50) COLLATE pg_catalog."default" NOT NULL,
-    CONSTRAINT roles_pkey PRIMARY KEY (id)
-);
-
 CREATE TABLE IF NOT EXISTS public.boards
 (
     id serial NOT NULL,
@@ -66,48 +60,43 @@ CREATE TABLE IF NOT EXISTS public.board_elements
     CONSTRAINT board_elements_pkey PRIMARY KEY (id)
 );
 
-ALTER TABLE IF EXISTS public.users
+-- Внешние ключи пересоздаются, так как ADD CONSTRAINT не поддерживает IF NOT EXISTS
+
+ALTER TABLE public.users DROP CONSTRAINT IF EXISTS users_role_id_fkey;
+ALTER TABLE public.users
     ADD CONSTRAINT users_role_id_fkey FOREIGN KEY (role_id)
     REFERENCES public.roles (id) MATCH SIMPLE
     ON UPDATE NO ACTION
     ON DELETE NO ACTION;
 
-
-ALTER TABLE IF EXISTS public.boards
+ALTER TABLE public.boards DROP CONSTRAINT IF EXISTS boards_creator_id_fkey;
+ALTER TABLE public.boards
     ADD CONSTRAINT boards_creator_id_fkey FOREIGN KEY (creator_id)
     REFERENCES public.users (id) MATCH SIMPLE
     ON UPDATE NO ACTION
     ON DELETE NO ACTION;
 
-
-ALTER TABLE IF EXISTS public.board_permissions
+ALTER TABLE public.board_permissions DROP CONSTRAINT IF EXISTS board_permissions_board_id_fkey;
+ALTER TABLE public.board_permissions
     ADD CONSTRAINT board_permissions_board_id_fkey FOREIGN KEY (board_id)
     REFERENCES public.boards (id) MATCH SIMPLE
     ON UPDATE NO ACTION
     ON DELETE NO ACTION;
 
-
-ALTER TABLE IF EXISTS public.board_permissions
+ALTER TABLE public.board_permissions DROP CONSTRAINT IF EXISTS board_permissions_user_id_fkey;
+ALTER TABLE public.board_permissions
     ADD CONSTRAINT board_permissions_user_id_fkey FOREIGN KEY (user_id)
     REFERENCES public.users (id) MATCH SIMPLE
     ON UPDATE NO ACTION
     ON DELETE NO ACTION;
 
-
-ALTER TABLE IF EXISTS public.board_elements
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_board_id_fkey;
+ALTER TABLE public.board_elements
     ADD CONSTRAINT board_elements_board_id_fkey FOREIGN KEY (board_id)
     REFERENCES public.boards (id) MATCH SIMPLE
     ON UPDATE NO ACTION
     ON DELETE NO ACTION;
 
-END;
-
-	`
-
-	_, err := db.Exec(query)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
\ No newline at end of file
+-- Роль по умолчанию, которую получают новые пользователи при регистрации
+INSERT INTO public.roles (id, name) VALUES (1, 'user') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('public.roles', 'id'), GREATEST((SELECT MAX(id) FROM public.roles), 1));
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.board_permissions_board_id_user_id_key;
@@ -0,0 +1,3 @@
+-- У пользователя может быть только одна запись о доступе к доске
+CREATE UNIQUE INDEX IF NOT EXISTS board_permissions_board_id_user_id_key
+    ON public.board_permissions (board_id, user_id);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS public.board_invites
+(
+    id serial NOT NULL,
+    board_id integer NOT NULL,
+    token character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    role character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    expires_at timestamp without time zone,
+    max_uses integer,
+    uses integer NOT NULL DEFAULT 0,
+    created_by integer,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT board_invites_pkey PRIMARY KEY (id),
+    CONSTRAINT board_invites_token_key UNIQUE (token),
+    CONSTRAINT board_invites_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.boards_public_slug_key;
+ALTER TABLE public.boards DROP COLUMN IF EXISTS public_slug;
@@ -0,0 +1,6 @@
+-- Непредсказуемый идентификатор для анонимного просмотра публичной доски
+ALTER TABLE public.boards
+    ADD COLUMN IF NOT EXISTS public_slug character varying(64) COLLATE pg_catalog."default";
+
+CREATE UNIQUE INDEX IF NOT EXISTS boards_public_slug_key
+    ON public.boards (public_slug);
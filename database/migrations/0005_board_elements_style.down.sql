@@ -0,0 +1 @@
+ALTER TABLE public.board_elements DROP COLUMN IF EXISTS style;
@@ -0,0 +1,3 @@
+-- Оформление элемента: заливка, обводка, шрифт и т.д. (см. models.ElementStyle)
+ALTER TABLE public.board_elements
+    ADD COLUMN IF NOT EXISTS style jsonb NOT NULL DEFAULT '{}'::jsonb;
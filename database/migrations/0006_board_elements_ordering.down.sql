@@ -0,0 +1,5 @@
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_board_id_z_index_key;
+ALTER TABLE public.board_elements
+    DROP COLUMN IF EXISTS locked,
+    DROP COLUMN IF EXISTS rotation,
+    DROP COLUMN IF EXISTS z_index;
@@ -0,0 +1,19 @@
+ALTER TABLE public.board_elements
+    ADD COLUMN IF NOT EXISTS z_index integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS rotation double precision NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked boolean NOT NULL DEFAULT false;
+
+-- Существующие элементы выстраиваются в порядке создания
+UPDATE public.board_elements e
+SET z_index = ordered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY board_id ORDER BY created_at, id) AS position
+    FROM public.board_elements
+) ordered
+WHERE e.id = ordered.id;
+
+-- Проверка откладывается до конца транзакции, чтобы перенумерация не натыкалась
+-- на временные дубликаты
+ALTER TABLE public.board_elements
+    ADD CONSTRAINT board_elements_board_id_z_index_key UNIQUE (board_id, z_index)
+    DEFERRABLE INITIALLY DEFERRED;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS public.board_elements_target_element_id_idx;
+DROP INDEX IF EXISTS public.board_elements_source_element_id_idx;
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_target_element_fkey;
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_source_element_fkey;
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_board_id_id_key;
+ALTER TABLE public.board_elements
+    DROP COLUMN IF EXISTS connector,
+    DROP COLUMN IF EXISTS target_element_id,
+    DROP COLUMN IF EXISTS source_element_id;
@@ -0,0 +1,25 @@
+ALTER TABLE public.board_elements
+    ADD COLUMN IF NOT EXISTS source_element_id integer,
+    ADD COLUMN IF NOT EXISTS target_element_id integer,
+    ADD COLUMN IF NOT EXISTS connector jsonb;
+
+-- Составной ключ позволяет коннектору ссылаться только на элементы своей доски
+ALTER TABLE public.board_elements
+    ADD CONSTRAINT board_elements_board_id_id_key UNIQUE (board_id, id);
+
+ALTER TABLE public.board_elements
+    ADD CONSTRAINT board_elements_source_element_fkey FOREIGN KEY (board_id, source_element_id)
+    REFERENCES public.board_elements (board_id, id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION;
+
+ALTER TABLE public.board_elements
+    ADD CONSTRAINT board_elements_target_element_fkey FOREIGN KEY (board_id, target_element_id)
+    REFERENCES public.board_elements (board_id, id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION;
+
+CREATE INDEX IF NOT EXISTS board_elements_source_element_id_idx
+    ON public.board_elements (source_element_id) WHERE source_element_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS board_elements_target_element_id_idx
+    ON public.board_elements (target_element_id) WHERE target_element_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.board_elements_parent_id_idx;
+ALTER TABLE public.board_elements DROP CONSTRAINT IF EXISTS board_elements_parent_fkey;
+ALTER TABLE public.board_elements DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,11 @@
+ALTER TABLE public.board_elements ADD COLUMN IF NOT EXISTS parent_id integer;
+
+-- Родитель, как и концы коннектора, может быть только элементом той же доски
+ALTER TABLE public.board_elements
+    ADD CONSTRAINT board_elements_parent_fkey FOREIGN KEY (board_id, parent_id)
+    REFERENCES public.board_elements (board_id, id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION;
+
+CREATE INDEX IF NOT EXISTS board_elements_parent_id_idx
+    ON public.board_elements (parent_id) WHERE parent_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE public.board_elements DROP COLUMN IF EXISTS version;
+ALTER TABLE public.boards DROP COLUMN IF EXISTS version;
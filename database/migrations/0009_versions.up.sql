@@ -0,0 +1,5 @@
+-- Версия доски растет при каждом изменении доски или её элементов.
+-- Элемент при изменении получает текущую версию доски, поэтому версии элементов
+-- тоже только растут.
+ALTER TABLE public.boards ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+ALTER TABLE public.board_elements ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
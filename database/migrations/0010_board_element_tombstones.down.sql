@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.board_elements_board_id_version_idx;
+DROP TABLE IF EXISTS public.board_element_tombstones;
@@ -0,0 +1,19 @@
+-- Надгробия удаленных элементов для ленты изменений доски
+CREATE TABLE IF NOT EXISTS public.board_element_tombstones
+(
+    element_id integer NOT NULL,
+    board_id integer NOT NULL,
+    version bigint NOT NULL,
+    deleted_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT board_element_tombstones_pkey PRIMARY KEY (element_id),
+    CONSTRAINT board_element_tombstones_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_board_id_version_idx
+    ON public.board_element_tombstones (board_id, version);
+
+CREATE INDEX IF NOT EXISTS board_elements_board_id_version_idx
+    ON public.board_elements (board_id, version);
@@ -0,0 +1,4 @@
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_target_element_fkey NOT DEFERRABLE;
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_source_element_fkey NOT DEFERRABLE;
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_parent_fkey NOT DEFERRABLE;
+DROP TABLE IF EXISTS public.board_revisions;
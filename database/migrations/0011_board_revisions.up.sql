@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS public.board_revisions
+(
+    id serial NOT NULL,
+    board_id integer NOT NULL,
+    board_version bigint NOT NULL,
+    kind character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    label character varying(200) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    author_id integer,
+    elements jsonb NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT board_revisions_pkey PRIMARY KEY (id),
+    CONSTRAINT board_revisions_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION,
+    CONSTRAINT board_revisions_author_id_fkey FOREIGN KEY (author_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS board_revisions_board_id_idx
+    ON public.board_revisions (board_id, created_at DESC);
+
+-- Восстановление ревизии заменяет все элементы доски сразу, поэтому ссылки между
+-- элементами можно проверять в конце транзакции
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_parent_fkey DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_source_element_fkey DEFERRABLE INITIALLY IMMEDIATE;
+ALTER TABLE public.board_elements ALTER CONSTRAINT board_elements_target_element_fkey DEFERRABLE INITIALLY IMMEDIATE;
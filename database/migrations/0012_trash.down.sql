@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS public.board_element_tombstones_deleted_at_idx;
+DROP INDEX IF EXISTS public.board_element_tombstones_trash_idx;
+ALTER TABLE public.board_element_tombstones
+    DROP CONSTRAINT IF EXISTS board_element_tombstones_deleted_by_fkey,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS root_id,
+    DROP COLUMN IF EXISTS element;
+
+-- Доски из корзины удаляются окончательно вместе со всем, что на них ссылается
+DELETE FROM public.board_elements WHERE board_id IN (SELECT id FROM public.boards WHERE deleted_at IS NOT NULL);
+DELETE FROM public.board_element_tombstones WHERE board_id IN (SELECT id FROM public.boards WHERE deleted_at IS NOT NULL);
+DELETE FROM public.board_revisions WHERE board_id IN (SELECT id FROM public.boards WHERE deleted_at IS NOT NULL);
+DELETE FROM public.board_permissions WHERE board_id IN (SELECT id FROM public.boards WHERE deleted_at IS NOT NULL);
+DELETE FROM public.board_invites WHERE board_id IN (SELECT id FROM public.boards WHERE deleted_at IS NOT NULL);
+DELETE FROM public.boards WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS public.boards_deleted_at_idx;
+ALTER TABLE public.boards DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,25 @@
+-- Корзина: удаленные доски помечаются deleted_at, удаленные элементы
+-- хранятся в надгробиях вместе со снимком до окончательного удаления
+ALTER TABLE public.boards
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp without time zone;
+
+CREATE INDEX IF NOT EXISTS boards_deleted_at_idx
+    ON public.boards (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE public.board_element_tombstones
+    ADD COLUMN IF NOT EXISTS element jsonb,
+    ADD COLUMN IF NOT EXISTS root_id integer,
+    ADD COLUMN IF NOT EXISTS deleted_by integer,
+    ADD CONSTRAINT board_element_tombstones_deleted_by_fkey FOREIGN KEY (deleted_by)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_trash_idx
+    ON public.board_element_tombstones (board_id, root_id)
+    WHERE element IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_deleted_at_idx
+    ON public.board_element_tombstones (deleted_at)
+    WHERE element IS NOT NULL;
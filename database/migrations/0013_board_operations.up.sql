@@ -0,0 +1,26 @@
+-- Журнал операций над элементами для отмены и повтора на сервере.
+-- changes - состояния затронутых элементов до и после операции,
+-- applied_version - версия доски, с которой действует текущая сторона операции.
+CREATE TABLE IF NOT EXISTS public.board_operations
+(
+    id serial NOT NULL,
+    board_id integer NOT NULL,
+    user_id integer NOT NULL,
+    kind character varying(20) COLLATE pg_catalog."default" NOT NULL,
+    changes jsonb NOT NULL,
+    applied_version bigint NOT NULL,
+    undone_at timestamp without time zone,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT board_operations_pkey PRIMARY KEY (id),
+    CONSTRAINT board_operations_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION,
+    CONSTRAINT board_operations_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS board_operations_board_id_user_id_idx
+    ON public.board_operations (board_id, user_id, id DESC);
@@ -0,0 +1,29 @@
+-- Фоновые выгрузки больших досок в PNG и PDF.
+-- params - область, масштаб и фон картинки, result - готовый файл до истечения срока хранения.
+CREATE TABLE IF NOT EXISTS public.board_export_jobs
+(
+    id serial NOT NULL,
+    board_id integer NOT NULL,
+    user_id integer NOT NULL,
+    format character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    params jsonb NOT NULL,
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'pending',
+    error text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    board_version bigint,
+    result bytea,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    started_at timestamp without time zone,
+    finished_at timestamp without time zone,
+    CONSTRAINT board_export_jobs_pkey PRIMARY KEY (id),
+    CONSTRAINT board_export_jobs_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION,
+    CONSTRAINT board_export_jobs_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS board_export_jobs_status_idx
+    ON public.board_export_jobs (status, created_at);
@@ -0,0 +1,17 @@
+-- Картинки-превью досок для списка досок.
+-- seen_version - версия доски при последней проверке фоновой задачей: превью рисуется,
+-- только когда доска не менялась между двумя проверками, а не на каждое перемещение элемента.
+-- board_version - версия доски, с которой нарисовано image.
+CREATE TABLE IF NOT EXISTS public.board_thumbnails
+(
+    board_id integer NOT NULL,
+    seen_version bigint NOT NULL,
+    board_version bigint,
+    image bytea,
+    updated_at timestamp without time zone,
+    CONSTRAINT board_thumbnails_pkey PRIMARY KEY (board_id),
+    CONSTRAINT board_thumbnails_board_id_fkey FOREIGN KEY (board_id)
+        REFERENCES public.boards (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+);
@@ -0,0 +1,41 @@
+-- Токены обновления. Токен одноразовый: при обновлении выдается следующий токен той же
+-- цепочки (family), а предъявленный помечается использованным. Повторное предъявление
+-- использованного токена означает, что его украли, и вся цепочка отзывается.
+-- Хранится только SHA-256 токена. access_jti - токен доступа, выданный вместе с этим
+-- токеном обновления: при отзыве цепочки он попадает в черный список.
+CREATE TABLE IF NOT EXISTS public.refresh_tokens
+(
+    id serial NOT NULL,
+    user_id integer NOT NULL,
+    family character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    token_hash character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    access_jti character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    access_expires_at timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    used_at timestamp without time zone,
+    revoked_at timestamp without time zone,
+    CONSTRAINT refresh_tokens_pkey PRIMARY KEY (id),
+    CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash),
+    CONSTRAINT refresh_tokens_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx
+    ON public.refresh_tokens (family);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx
+    ON public.refresh_tokens (user_id);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_access_jti_idx
+    ON public.refresh_tokens (access_jti);
+
+-- Черный список отозванных токенов доступа. Запись нужна, только пока токен не истек.
+CREATE TABLE IF NOT EXISTS public.revoked_tokens
+(
+    jti character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    CONSTRAINT revoked_tokens_pkey PRIMARY KEY (jti)
+);
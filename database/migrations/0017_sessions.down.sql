@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS public.refresh_tokens_session_id_idx;
+ALTER TABLE public.refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_session_id_fkey;
+ALTER TABLE public.refresh_tokens RENAME COLUMN session_id TO family;
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx
+    ON public.refresh_tokens (family);
+DROP TABLE IF EXISTS public.sessions;
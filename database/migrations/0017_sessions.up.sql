@@ -0,0 +1,39 @@
+-- Сессии пользователей: одна сессия на вход, продлевается обновлением токенов.
+-- ID сессии - случайная строка, раньше служившая идентификатором цепочки токенов обновления,
+-- поэтому уже выданные токены обновления становятся токенами своих сессий.
+CREATE TABLE IF NOT EXISTS public.sessions
+(
+    id character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    user_id integer NOT NULL,
+    user_agent text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    ip character varying(64) COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    last_used_at timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    revoked_at timestamp without time zone,
+    CONSTRAINT sessions_pkey PRIMARY KEY (id),
+    CONSTRAINT sessions_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx
+    ON public.sessions (user_id);
+
+INSERT INTO public.sessions (id, user_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT family, user_id, MIN(created_at), MAX(created_at), MAX(expires_at),
+       CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM public.refresh_tokens
+GROUP BY family, user_id;
+
+DROP INDEX IF EXISTS public.refresh_tokens_family_idx;
+ALTER TABLE public.refresh_tokens RENAME COLUMN family TO session_id;
+ALTER TABLE public.refresh_tokens
+    ADD CONSTRAINT refresh_tokens_session_id_fkey FOREIGN KEY (session_id)
+        REFERENCES public.sessions (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx
+    ON public.refresh_tokens (session_id);
@@ -0,0 +1,16 @@
+-- Одноразовые токены сброса пароля. Хранится только SHA-256 токена из письма.
+CREATE TABLE IF NOT EXISTS public.password_reset_tokens
+(
+    id serial NOT NULL,
+    user_id integer NOT NULL,
+    token_hash character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    used_at timestamp without time zone,
+    CONSTRAINT password_reset_tokens_pkey PRIMARY KEY (id),
+    CONSTRAINT password_reset_tokens_token_hash_key UNIQUE (token_hash),
+    CONSTRAINT password_reset_tokens_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
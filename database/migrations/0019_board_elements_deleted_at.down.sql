@@ -0,0 +1,45 @@
+DROP INDEX IF EXISTS public.board_element_tombstones_deleted_at_idx;
+
+-- Элементы из корзины снова хранятся снимками в надгробиях
+ALTER TABLE public.board_element_tombstones
+    ADD COLUMN IF NOT EXISTS element jsonb,
+    ADD COLUMN IF NOT EXISTS root_id integer,
+    ADD COLUMN IF NOT EXISTS deleted_by integer,
+    ADD CONSTRAINT board_element_tombstones_deleted_by_fkey FOREIGN KEY (deleted_by)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_trash_idx
+    ON public.board_element_tombstones (board_id, root_id)
+    WHERE element IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_deleted_at_idx
+    ON public.board_element_tombstones (deleted_at)
+    WHERE element IS NOT NULL;
+
+UPDATE public.board_element_tombstones t
+SET element = jsonb_build_object(
+        'id', e.id, 'board_id', e.board_id, 'type', e.type, 'content', COALESCE(e.content, ''),
+        'position_x', COALESCE(e.position_x, 0), 'position_y', COALESCE(e.position_y, 0),
+        'width', COALESCE(e.width, 0), 'height', COALESCE(e.height, 0), 'style', e.style,
+        'z_index', 0, 'rotation', e.rotation, 'locked', e.locked, 'parent_id', e.parent_id, 'version', e.version,
+        'created_at', to_char(e.created_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'),
+        'updated_at', to_char(e.updated_at, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'))
+        || CASE WHEN e.connector IS NULL THEN '{}'::jsonb ELSE jsonb_build_object('connector', e.connector) END,
+    root_id = e.trash_root_id,
+    deleted_by = e.deleted_by
+FROM public.board_elements e
+WHERE e.id = t.element_id AND e.deleted_at IS NOT NULL;
+
+DELETE FROM public.board_elements WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE public.boards DROP COLUMN IF EXISTS pruned_version;
+
+DROP INDEX IF EXISTS public.board_elements_deleted_at_idx;
+DROP INDEX IF EXISTS public.board_elements_trash_idx;
+ALTER TABLE public.board_elements
+    DROP CONSTRAINT IF EXISTS board_elements_deleted_by_fkey,
+    DROP COLUMN IF EXISTS trash_root_id,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,72 @@
+-- Элементы в корзине остаются в board_elements с пометкой deleted_at,
+-- надгробия нужны только ленте изменений
+ALTER TABLE public.board_elements
+    ADD COLUMN IF NOT EXISTS deleted_at timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS deleted_by integer,
+    ADD COLUMN IF NOT EXISTS trash_root_id integer,
+    ADD CONSTRAINT board_elements_deleted_by_fkey FOREIGN KEY (deleted_by)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS board_elements_trash_idx
+    ON public.board_elements (board_id, trash_root_id)
+    WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS board_elements_deleted_at_idx
+    ON public.board_elements (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+-- Надгробия с версией не больше pruned_version удалены при очистке корзины,
+-- более старый курсор ленты изменений отклоняется
+ALTER TABLE public.boards
+    ADD COLUMN IF NOT EXISTS pruned_version bigint NOT NULL DEFAULT 0;
+
+-- Снимки из корзины возвращаются в board_elements. Порядок наложения элемента в корзине
+-- отрицательный, чтобы не пересекаться с элементами на доске
+INSERT INTO public.board_elements (id, board_id, type, content, position_x, position_y, width, height, style, z_index, rotation, locked,
+                                   connector, version, created_at, updated_at, deleted_at, deleted_by, trash_root_id)
+SELECT t.element_id, t.board_id, t.element->>'type', t.element->>'content',
+       (t.element->>'position_x')::integer, (t.element->>'position_y')::integer,
+       (t.element->>'width')::integer, (t.element->>'height')::integer,
+       COALESCE(t.element->'style', '{}'::jsonb), -t.element_id,
+       COALESCE((t.element->>'rotation')::double precision, 0), COALESCE((t.element->>'locked')::boolean, false),
+       t.element->'connector', t.version,
+       (t.element->>'created_at')::timestamp, (t.element->>'updated_at')::timestamp,
+       t.deleted_at, t.deleted_by, t.root_id
+FROM public.board_element_tombstones t
+WHERE t.element IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM public.board_elements e WHERE e.id = t.element_id);
+
+-- Ссылки восстанавливаются, только если элемент, на который они указывают, еще есть в базе
+UPDATE public.board_elements e
+SET parent_id = (t.element->>'parent_id')::integer
+FROM public.board_element_tombstones t
+WHERE t.element_id = e.id AND t.element IS NOT NULL AND e.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM public.board_elements p
+              WHERE p.board_id = e.board_id AND p.id = (t.element->>'parent_id')::integer);
+
+UPDATE public.board_elements e
+SET source_element_id = (t.element->'connector'->'source'->>'element_id')::integer
+FROM public.board_element_tombstones t
+WHERE t.element_id = e.id AND t.element IS NOT NULL AND e.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM public.board_elements p
+              WHERE p.board_id = e.board_id AND p.id = (t.element->'connector'->'source'->>'element_id')::integer);
+
+UPDATE public.board_elements e
+SET target_element_id = (t.element->'connector'->'target'->>'element_id')::integer
+FROM public.board_element_tombstones t
+WHERE t.element_id = e.id AND t.element IS NOT NULL AND e.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM public.board_elements p
+              WHERE p.board_id = e.board_id AND p.id = (t.element->'connector'->'target'->>'element_id')::integer);
+
+DROP INDEX IF EXISTS public.board_element_tombstones_deleted_at_idx;
+DROP INDEX IF EXISTS public.board_element_tombstones_trash_idx;
+ALTER TABLE public.board_element_tombstones
+    DROP CONSTRAINT IF EXISTS board_element_tombstones_deleted_by_fkey,
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS root_id,
+    DROP COLUMN IF EXISTS element;
+
+CREATE INDEX IF NOT EXISTS board_element_tombstones_deleted_at_idx
+    ON public.board_element_tombstones (deleted_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.board_export_jobs_user_id_idx;
+ALTER TABLE public.board_export_jobs DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,8 @@
+-- Сколько раз выгрузку брали в работу. Выгрузка, которая роняет сервер,
+-- после нескольких попыток завершается со статусом failed.
+ALTER TABLE public.board_export_jobs
+    ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 0;
+
+-- Поиск одинаковых выгрузок пользователя и подсчет его незавершенных выгрузок
+CREATE INDEX IF NOT EXISTS board_export_jobs_user_id_idx
+    ON public.board_export_jobs (user_id, board_id, status);
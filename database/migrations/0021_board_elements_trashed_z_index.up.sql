@@ -0,0 +1,4 @@
+-- Порядок наложения элемента до переноса в корзину. При восстановлении элементы
+-- кладутся поверх доски в этом порядке.
+ALTER TABLE public.board_elements
+    ADD COLUMN IF NOT EXISTS trashed_z_index integer;
@@ -1,20 +1,89 @@
 package handlers
 
 import (
-	"database/sql"
-	"micromiro/database"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"micromiro/mail"
 	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/store"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
     "github.com/golang-jwt/jwt/v5"
     "golang.org/x/crypto/bcrypt"
 )
 
+// AuthConfig - время жизни токенов и адрес фронтенда для ссылок в письмах
+type AuthConfig struct {
+	// AccessTokenTTL - время жизни токена доступа. Короткое, чтобы украденный токен
+	// недолго оставался полезным.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL - время жизни токена обновления, то есть сессии без активности
+	RefreshTokenTTL time.Duration
+	// PasswordResetTTL - сколько действует ссылка сброса пароля из письма
+	PasswordResetTTL time.Duration
+	// AppURL - адрес фронтенда, на который ведут ссылки в письмах
+	AppURL string
+}
+
+// LoadAuthConfig читает настройки из переменных окружения ACCESS_TOKEN_TTL, REFRESH_TOKEN_TTL,
+// PASSWORD_RESET_TTL (в формате Go, например "15m") и APP_URL
+func LoadAuthConfig() (AuthConfig, error) {
+	config := AuthConfig{
+		AccessTokenTTL:   15 * time.Minute,
+		RefreshTokenTTL:  30 * 24 * time.Hour,
+		PasswordResetTTL: time.Hour,
+		AppURL:           "http://localhost:5173",
+	}
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		config.AppURL = strings.TrimSuffix(appURL, "/")
+	}
+	for name, value := range map[string]*time.Duration{
+		"ACCESS_TOKEN_TTL":   &config.AccessTokenTTL,
+		"REFRESH_TOKEN_TTL":  &config.RefreshTokenTTL,
+		"PASSWORD_RESET_TTL": &config.PasswordResetTTL,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid %s: %q", name, raw)
+		}
+		*value = d
+	}
+	return config, nil
+}
+
+// Длинные заголовки User-Agent обрезаются при сохранении сессии
+const maxUserAgentLength = 512
+
+// AuthHandler обрабатывает регистрацию, вход пользователей, их сессии и восстановление пароля
+type AuthHandler struct {
+	users  store.UserStore
+	tokens store.TokenStore
+	mailer mail.Mailer
+	hub    *realtime.Hub
+	config AuthConfig
+	logger *log.Logger
+}
+
+// NewAuthHandler создает обработчик аутентификации с переданными хранилищами.
+// Письма отправляются через mailer, ошибки отправки пишутся в logger.
+// Через hub закрываются WebSocket-подключения завершенных сессий.
+func NewAuthHandler(users store.UserStore, tokens store.TokenStore, mailer mail.Mailer, hub *realtime.Hub, config AuthConfig, logger *log.Logger) *AuthHandler {
+	return &AuthHandler{users: users, tokens: tokens, mailer: mailer, hub: hub, config: config, logger: logger}
+}
 
-func Register(c *gin.Context) {
+func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -27,36 +96,32 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	db, _ := database.ConnectDB()
-	defer db.Close()
-
-	query := `INSERT INTO users (username, email, password, role_id, created_at, updated_at) 
-              VALUES ($1, $2, $3, 1, $4, $5) RETURNING id`
-
-	var userID int
-	err = db.QueryRow(query, req.Username, req.Email, hashedPassword, time.Now(), time.Now()).Scan(&userID)
+	user := models.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Password:  string(hashedPassword),
+		RoleID:    1,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err = h.users.CreateUser(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания пользователя"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Пользователь успешно зарегистрирован", "user_id": userID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Пользователь успешно зарегистрирован", "user_id": user.ID})
 }
 
-func Login(c *gin.Context) {
+func (h *AuthHandler) Login(c *gin.Context) {
     var req models.LoginRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
 
-    db, _ := database.ConnectDB()
-    defer db.Close()
-
-    var user models.User
-    query := `SELECT id, username, email, password, role_id FROM users WHERE email = $1`
-    err := db.QueryRow(query, req.Email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.RoleID)
-    if err == sql.ErrNoRows {
+    user, err := h.users.GetUserByEmail(c.Request.Context(), req.Email)
+    if errors.Is(err, store.ErrNotFound) {
         c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
         return
     } else if err != nil {
@@ -70,23 +135,186 @@ func Login(c *gin.Context) {
         return
     }
 
-    // Генерируем JWT токен
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-        "user_id": user.ID,
-        "email":   user.Email,
-        "role_id": user.RoleID,
-        "exp":     time.Now().Add(time.Hour * 24).Unix(), // Токен истекает через 24 часа
-    })
-
-    secret := os.Getenv("JWT_SECRET")
-    if secret == "" {
-        secret = "your-secret-key" // Укажи в .env
-    }
-    tokenString, err := token.SignedString([]byte(secret))
+    // Каждый вход начинает новую сессию
+    sessionID, err := randomToken(24)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
         return
     }
+    h.issueTokens(c, user, sessionID, "")
+}
+
+// Refresh обменивает токен обновления на новую пару токенов. Предъявленный токен
+// становится недействительным; его повторное предъявление считается кражей и
+// завершает сессию, начатую тем же входом.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash := hashToken(req.RefreshToken)
+	current, err := h.tokens.GetRefreshToken(c.Request.Context(), hash)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Недействительный токен обновления"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	user, err := h.users.GetUserByID(c.Request.Context(), current.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Недействительный токен обновления"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	h.issueTokens(c, user, current.SessionID, hash)
+}
+
+// Logout завершает текущую сессию: токен доступа из запроса и все токены обновления,
+// полученные тем же входом, перестают действовать
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID, sessionID, ok := requestSession(c)
+	if !ok {
+		return
+	}
+
+	// Истекшая сессия уже завершена, выходить из неё повторно незачем
+	err := h.tokens.RevokeSession(c.Request.Context(), userID, sessionID, time.Now())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессии"})
+		return
+	}
+	h.hub.DisconnectSessions(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
+// LogoutAll завершает все сессии пользователя, например если потеряно устройство.
+// Токены доступа других сессий попадают в черный список сразу, не дожидаясь истечения.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if err := h.tokens.RevokeUserSessions(c.Request.Context(), userID.(int), time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессий"})
+		return
+	}
+	h.hub.DisconnectUser(userID.(int))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Все сессии завершены"})
+}
+
+// issueTokens выдает пользователю токен доступа и токен обновления сессии sessionID.
+// Если задан previousHash, предъявленный токен обновления обменивается на новый,
+// иначе начинается новая сессия с устройства, с которого сделан запрос.
+func (h *AuthHandler) issueTokens(c *gin.Context, user *models.User, sessionID, previousHash string) {
+	now := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	record := &models.RefreshToken{
+		UserID:          user.ID,
+		SessionID:       sessionID,
+		TokenHash:       hashToken(refresh),
+		AccessJTI:       jti,
+		AccessExpiresAt: now.Add(h.config.AccessTokenTTL),
+		ExpiresAt:       now.Add(h.config.RefreshTokenTTL),
+		CreatedAt:       now,
+	}
 
-    c.JSON(http.StatusOK, gin.H{"token": tokenString})
-}
\ No newline at end of file
+	access, err := signAccessToken(user, jti, sessionID, now, record.AccessExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	if previousHash == "" {
+		session := &models.Session{
+			ID:         sessionID,
+			UserID:     user.ID,
+			UserAgent:  truncate(c.Request.UserAgent(), maxUserAgentLength),
+			IP:         c.ClientIP(),
+			CreatedAt:  now,
+			LastUsedAt: now,
+			ExpiresAt:  record.ExpiresAt,
+		}
+		err = h.tokens.CreateSession(c.Request.Context(), session, record)
+	} else {
+		err = h.tokens.RotateRefreshToken(c.Request.Context(), previousHash, record, now)
+	}
+	switch {
+	case errors.Is(err, store.ErrTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Токен обновления уже использован, сессия завершена. Войдите заново"})
+		return
+	case errors.Is(err, store.ErrTokenExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Срок действия токена обновления истек. Войдите заново"})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Недействительный токен обновления"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         access,
+		"refresh_token": refresh,
+		"expires_in":    int(h.config.AccessTokenTTL.Seconds()),
+	})
+}
+
+// signAccessToken подписывает токен доступа сессии sessionID. jti позволяет отозвать
+// токен до истечения.
+func signAccessToken(user *models.User, jti, sessionID string, issuedAt, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": user.ID,
+		"email":   user.Email,
+		"role_id": user.RoleID,
+		"jti":     jti,
+		"sid":     sessionID,
+		"iat":     issuedAt.Unix(),
+		"exp":     expiresAt.Unix(),
+	})
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = "your-secret-key" // Укажи в .env
+	}
+	return token.SignedString([]byte(secret))
+}
+
+// requestSession возвращает пользователя и сессию, из которой сделан запрос.
+// При ошибке отвечает и возвращает false.
+func requestSession(c *gin.Context) (int, string, bool) {
+	userID, userExists := c.Get("user_id")
+	sessionID, sessionExists := c.Get("session_id")
+	if !userExists || !sessionExists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return 0, "", false
+	}
+	return userID.(int), sessionID.(string), true
+}
+
+// hashToken возвращает хеш токена для хранения в БД
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// ApplyElementBatch применяет пакет операций create/update/delete над элементами доски
+// в одной транзакции с одной проверкой прав. Если хотя бы одна операция не прошла,
+// не применяется ни одна. В ответе - результат каждой операции и ID созданных элементов
+// по их temp_id.
+func (h *BoardHandler) ApplyElementBatch(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	var req models.ElementBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	results := make([]models.ElementOperationResult, len(req.Operations))
+	for i, op := range req.Operations {
+		results[i] = models.ElementOperationResult{Index: i, Op: op.Op, TempID: op.TempID, ElementID: op.ElementID, Status: "skipped"}
+	}
+
+	// Проверяем все операции до обращения к базе
+	now := time.Now()
+	ops := make([]store.ElementOp, len(req.Operations))
+	tempIDs := make(map[string]bool)
+	for i, op := range req.Operations {
+		var err error
+		ops[i], err = batchOperation(op, now)
+		if err == nil && op.TempID != "" {
+			if tempIDs[op.TempID] {
+				err = fmt.Errorf("temp_id %q повторяется", op.TempID)
+			}
+			tempIDs[op.TempID] = true
+		}
+		if err != nil {
+			results[i].Status = "error"
+			results[i].Error = err.Error()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "results": results})
+			return
+		}
+	}
+
+	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+	for i, op := range ops {
+		if op.Kind == store.OpCreate && op.Element.Locked && !access.IsCreator {
+			results[i].Status = "error"
+			results[i].Error = errLockChange
+			c.JSON(http.StatusForbidden, gin.H{"error": errLockChange, "results": results})
+			return
+		}
+	}
+
+	applied, err := h.boards.ApplyElementBatch(c.Request.Context(), boardID, userID.(int), ops, access.IsCreator)
+	var batchErr *store.BatchError
+	if errors.As(err, &batchErr) {
+		status, message := batchErrorResponse(batchErr.Err)
+		results[batchErr.Index].Status = "error"
+		results[batchErr.Index].Error = message
+		c.JSON(status, gin.H{"error": message, "results": results})
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка пакетного изменения элементов"})
+		return
+	}
+
+	// Оповещаем остальных участников доски
+	created := make(map[string]int)
+	for i, op := range ops {
+		results[i].Status = "ok"
+		switch op.Kind {
+		case store.OpCreate:
+			results[i].ElementID = op.Element.ID
+			if tempID := req.Operations[i].TempID; tempID != "" {
+				created[tempID] = op.Element.ID
+			}
+			h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementCreated, Payload: op.Element})
+		case store.OpUpdate:
+			h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: applied[i].Element})
+			for _, child := range applied[i].Moved {
+				h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: child})
+			}
+		case store.OpDelete:
+			h.broadcastDeletion(boardID, op.ElementID, applied[i].Deletion)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пакет операций применен", "results": results, "created": created})
+}
+
+// batchOperation проверяет операцию пакета и строит операцию хранилища.
+// element операции update применяется к текущему состоянию элемента как патч PATCH.
+func batchOperation(op models.ElementOperation, now time.Time) (store.ElementOp, error) {
+	result := store.ElementOp{
+		Kind:      op.Op,
+		ElementID: op.ElementID,
+		IfMatch:   op.IfMatch,
+		Delete: store.DeleteElementOptions{
+			DetachConnectors: op.Connectors == "detach",
+			CascadeChildren:  op.Children == "cascade",
+		},
+	}
+	if op.Op == store.OpDelete {
+		if op.ElementID <= 0 {
+			return result, errors.New("Для удаления нужен element_id")
+		}
+		return result, nil
+	}
+
+	if len(op.Element) == 0 || string(op.Element) == "null" {
+		return result, errors.New("Для создания и обновления нужно поле element")
+	}
+	if op.Op == store.OpUpdate {
+		if op.ElementID <= 0 {
+			return result, errors.New("Для обновления нужен element_id")
+		}
+		var patch map[string]interface{}
+		if err := json.Unmarshal(op.Element, &patch); err != nil || patch == nil {
+			return result, errors.New("Поле element должно быть JSON-объектом")
+		}
+		result.Modify = func(element *models.BoardElement) error {
+			return applyElementPatch(element, patch)
+		}
+		return result, nil
+	}
+
+	var req models.CreateBoardElementRequest
+	if err := json.Unmarshal(op.Element, &req); err != nil {
+		return result, err
+	}
+	if req.Type == "" {
+		return result, errors.New("Для создания нужен тип элемента")
+	}
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return result, err
+	}
+	if err := models.ValidateConnector(req.Type, req.Connector); err != nil {
+		return result, err
+	}
+
+	result.Element = &models.BoardElement{
+		Type:      req.Type,
+		Content:   req.Content,
+		PositionX: req.PositionX,
+		PositionY: req.PositionY,
+		Width:     req.Width,
+		Height:    req.Height,
+		Style:     req.Style,
+		Rotation:  req.Rotation,
+		Locked:    req.Locked,
+		Connector: req.Connector,
+		ParentID:  req.ParentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return result, nil
+}
+
+// batchErrorResponse подбирает код и сообщение для ошибки операции пакета
+func batchErrorResponse(err error) (int, string) {
+	var patchErr *invalidPatchError
+	switch {
+	case errors.As(err, &patchErr):
+		return http.StatusUnprocessableEntity, patchErr.message
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound, "Элемент не найден или не принадлежит указанной доске"
+	case errors.Is(err, store.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, errVersionMismatch
+	case errors.Is(err, store.ErrLocked):
+		return http.StatusForbidden, "Элемент заблокирован, изменить его может только создатель доски"
+	case errors.Is(err, store.ErrLockChange):
+		return http.StatusForbidden, errLockChange
+	case errors.Is(err, store.ErrInvalidReference):
+		return http.StatusBadRequest, errInvalidConnectorRef
+	case errors.Is(err, store.ErrInvalidParent):
+		return http.StatusBadRequest, errInvalidParent
+	default:
+		return http.StatusInternalServerError, "Ошибка пакетного изменения элементов"
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
-	"database/sql"
-	"micromiro/database"
+	"errors"
 	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/render"
+	"micromiro/store"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,8 +13,21 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// BoardHandler обрабатывает запросы к доскам и их элементам
+type BoardHandler struct {
+	boards   store.BoardStore
+	users    store.UserStore
+	hub      *realtime.Hub
+	renderer *render.Renderer
+}
+
+// NewBoardHandler создает обработчик досок с переданными хранилищами
+func NewBoardHandler(boards store.BoardStore, users store.UserStore, hub *realtime.Hub, renderer *render.Renderer) *BoardHandler {
+	return &BoardHandler{boards: boards, users: users, hub: hub, renderer: renderer}
+}
+
 // CreateBoard создает новую доску
-func CreateBoard(c *gin.Context) {
+func (h *BoardHandler) CreateBoard(c *gin.Context) {
 	var req models.CreateBoardRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -25,90 +40,52 @@ func CreateBoard(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
+	publicSlug, err := randomToken(publicSlugSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания доски"})
 		return
 	}
-	defer db.Close()
-
-	query := `INSERT INTO boards (title, description, creator_id, is_public, created_at, updated_at) 
-              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
 
-	var boardID int
-	err = db.QueryRow(query, req.Title, req.Description, userID, req.IsPublic, time.Now(), time.Now()).Scan(&boardID)
-	if err != nil {
+	now := time.Now()
+	board := models.Board{
+		Title:       req.Title,
+		Description: req.Description,
+		CreatorID:   userID.(int),
+		IsPublic:    req.IsPublic,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := h.boards.CreateBoard(c.Request.Context(), &board, publicSlug); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания доски"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Доска успешно создана", "board_id": boardID})
+	c.JSON(http.StatusCreated, gin.H{"message": "Доска успешно создана", "board_id": board.ID})
 }
 
 // GetBoards получает список досок пользователя
-func GetBoards(c *gin.Context) {
+func (h *BoardHandler) GetBoards(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
-		return
-	}
-	defer db.Close()
-
-	// Получаем доски, созданные пользователем
-	query := `SELECT id, title, description, creator_id, is_public, created_at, updated_at 
-              FROM boards 
-              WHERE creator_id = $1`
-
-	rows, err := db.Query(query, userID)
+	// Доски, созданные пользователем, и доски, к которым у него есть доступ через разрешения
+	boards, err := h.boards.ListBoards(c.Request.Context(), userID.(int))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения досок"})
 		return
 	}
-	defer rows.Close()
-
-	boards := []models.Board{}
-	for rows.Next() {
-		var board models.Board
-		if err := rows.Scan(&board.ID, &board.Title, &board.Description, &board.CreatorID, &board.IsPublic, &board.CreatedAt, &board.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения данных"})
-			return
-		}
-		boards = append(boards, board)
-	}
-
-	// Также получаем доски, к которым у пользователя есть доступ через разрешения
-	query = `SELECT b.id, b.title, b.description, b.creator_id, b.is_public, b.created_at, b.updated_at 
-             FROM boards b
-             JOIN board_permissions bp ON b.id = bp.board_id
-             WHERE bp.user_id = $1 AND b.creator_id != $1`
-
-	rows, err = db.Query(query, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения досок с разрешениями"})
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var board models.Board
-		if err := rows.Scan(&board.ID, &board.Title, &board.Description, &board.CreatorID, &board.IsPublic, &board.CreatedAt, &board.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения данных"})
-			return
-		}
-		boards = append(boards, board)
+	for i := range boards {
+		boards[i].ThumbnailURL = thumbnailURL(boards[i])
 	}
 
 	c.JSON(http.StatusOK, boards)
 }
 
 // GetBoard получает информацию о конкретной доске
-func GetBoard(c *gin.Context) {
+func (h *BoardHandler) GetBoard(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -121,57 +98,37 @@ func GetBoard(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+	// Проверяем, имеет ли пользователь доступ к доске
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
 		return
 	}
-	defer db.Close()
 
-	// Проверяем, имеет ли пользователь доступ к доске
-	var board models.Board
-	query := `SELECT id, title, description, creator_id, is_public, created_at, updated_at 
-              FROM boards 
-              WHERE id = $1 AND (creator_id = $2 OR is_public = true OR EXISTS (
-                  SELECT 1 FROM board_permissions WHERE board_id = $1 AND user_id = $2
-              ))`
-
-	err = db.QueryRow(query, boardID, userID).Scan(&board.ID, &board.Title, &board.Description, &board.CreatorID, &board.IsPublic, &board.CreatedAt, &board.UpdatedAt)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена или у вас нет доступа"})
-		return
-	} else if err != nil {
+	board, err := h.boards.GetBoard(c.Request.Context(), boardID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
 		return
 	}
 
-	// Получаем элементы доски
-	query = `SELECT id, board_id, type, content, position_x, position_y, width, height, created_at, updated_at 
-             FROM board_elements 
-             WHERE board_id = $1`
+	// Версия доски меняется при любом изменении доски или её элементов
+	c.Header("ETag", etag(board.Version))
+	if notModified(c, board.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 
-	rows, err := db.Query(query, boardID)
+	// Получаем элементы доски. Версия прочитана раньше, поэтому ETag не новее ответа.
+	elements, err := h.boards.ListElements(c.Request.Context(), boardID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения элементов доски"})
 		return
 	}
-	defer rows.Close()
-
-	elements := []models.BoardElement{}
-	for rows.Next() {
-		var element models.BoardElement
-		if err := rows.Scan(&element.ID, &element.BoardID, &element.Type, &element.Content, &element.PositionX, &element.PositionY, &element.Width, &element.Height, &element.CreatedAt, &element.UpdatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения данных элементов"})
-			return
-		}
-		elements = append(elements, element)
-	}
+	board.ThumbnailURL = thumbnailURL(*board)
 
-	c.JSON(http.StatusOK, gin.H{"board": board, "elements": elements})
+	c.JSON(http.StatusOK, gin.H{"board": board, "elements": elements, "hierarchy": models.BuildHierarchy(elements)})
 }
 
 // UpdateBoard обновляет информацию о доске
-func UpdateBoard(c *gin.Context) {
+func (h *BoardHandler) UpdateBoard(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -190,52 +147,31 @@ func UpdateBoard(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
-		return
-	}
-	defer db.Close()
-
-	// Проверяем, имеет ли пользователь права на редактирование доски
-	var creatorID int
-	query := `SELECT creator_id FROM boards WHERE id = $1`
-	err = db.QueryRow(query, boardID).Scan(&creatorID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
 	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
-	if creatorID != userID.(int) {
-		var canEdit bool
-		query = `SELECT can_edit FROM board_permissions WHERE board_id = $1 AND user_id = $2`
-		err = db.QueryRow(query, boardID, userID).Scan(&canEdit)
-		if err == sql.ErrNoRows || !canEdit {
-			c.JSON(http.StatusForbidden, gin.H{"error": "У вас нет прав на редактирование этой доски"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав доступа"})
-			return
-		}
+	if _, ok := h.requireEditAccess(c, boardID, userID.(int)); !ok {
+		return
 	}
 
-	// Обновляем доску
-	query = `UPDATE boards SET title = $1, description = $2, is_public = $3, updated_at = $4 WHERE id = $5`
-	_, err = db.Exec(query, req.Title, req.Description, req.IsPublic, time.Now(), boardID)
-	if err != nil {
+	version, err := h.boards.UpdateBoard(c.Request.Context(), boardID, req, ifMatch)
+	if errors.Is(err, store.ErrVersionMismatch) {
+		h.boardPreconditionFailed(c, boardID)
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления доски"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Доска успешно обновлена"})
+	c.Header("ETag", etag(version))
+	c.JSON(http.StatusOK, gin.H{"message": "Доска успешно обновлена", "version": version})
 }
 
 // DeleteBoard удаляет доску
-func DeleteBoard(c *gin.Context) {
+func (h *BoardHandler) DeleteBoard(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -248,18 +184,14 @@ func DeleteBoard(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
-	defer db.Close()
 
-	// Проверяем, является ли пользователь создателем доски
-	var creatorID int
-	query := `SELECT creator_id FROM boards WHERE id = $1`
-	err = db.QueryRow(query, boardID).Scan(&creatorID)
-	if err == sql.ErrNoRows {
+	// Удалить доску может только её создатель
+	access, err := h.boards.GetAccess(c.Request.Context(), boardID, userID.(int))
+	if errors.Is(err, store.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
 		return
 	} else if err != nil {
@@ -267,53 +199,30 @@ func DeleteBoard(c *gin.Context) {
 		return
 	}
 
-	if creatorID != userID.(int) {
+	if !access.IsCreator {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Только создатель доски может удалить её"})
 		return
 	}
 
-	// Начинаем транзакцию для удаления доски и всех связанных данных
-	tx, err := db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка начала транзакции"})
-		return
-	}
-
-	// Удаляем элементы доски
-	_, err = tx.Exec(`DELETE FROM board_elements WHERE board_id = $1`, boardID)
-	if err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления элементов доски"})
-		return
-	}
-
-	// Удаляем разрешения доски
-	_, err = tx.Exec(`DELETE FROM board_permissions WHERE board_id = $1`, boardID)
-	if err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления разрешений доски"})
+	// Доска переносится в корзину вместе со всеми связанными данными
+	err = h.boards.DeleteBoard(c.Request.Context(), boardID, ifMatch)
+	if errors.Is(err, store.ErrVersionMismatch) {
+		h.boardPreconditionFailed(c, boardID)
 		return
-	}
-
-	// Удаляем саму доску
-	_, err = tx.Exec(`DELETE FROM boards WHERE id = $1`, boardID)
-	if err != nil {
-		tx.Rollback()
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления доски"})
 		return
 	}
 
-	// Завершаем транзакцию
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения транзакции"})
-		return
-	}
+	// Участники сначала получают событие удаления, затем их подключения закрываются
+	h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventBoardDeleted})
+	h.hub.DisconnectBoard(boardID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Доска успешно удалена"})
+	c.JSON(http.StatusOK, gin.H{"message": "Доска перемещена в корзину"})
 }
 
 // CreateBoardElement создает новый элемент на доске
-func CreateBoardElement(c *gin.Context) {
+func (h *BoardHandler) CreateBoardElement(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -325,6 +234,10 @@ func CreateBoardElement(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := models.ValidateConnector(req.Type, req.Connector); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -332,55 +245,55 @@ func CreateBoardElement(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
 		return
 	}
-	defer db.Close()
-
-	// Проверяем, имеет ли пользователь права на редактирование доски
-	var creatorID int
-	query := `SELECT creator_id FROM boards WHERE id = $1`
-	err = db.QueryRow(query, boardID).Scan(&creatorID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+	if req.Locked && !access.IsCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": errLockChange})
 		return
 	}
 
-	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
-	if creatorID != userID.(int) {
-		var canEdit bool
-		query = `SELECT can_edit FROM board_permissions WHERE board_id = $1 AND user_id = $2`
-		err = db.QueryRow(query, boardID, userID).Scan(&canEdit)
-		if err == sql.ErrNoRows || !canEdit {
-			c.JSON(http.StatusForbidden, gin.H{"error": "У вас нет прав на редактирование этой доски"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав доступа"})
-			return
-		}
-	}
-
 	// Добавляем новый элемент
-	query = `INSERT INTO board_elements (board_id, type, content, position_x, position_y, width, height, created_at, updated_at) 
-             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
-
-	var elementID int
-	err = db.QueryRow(query, boardID, req.Type, req.Content, req.PositionX, req.PositionY, req.Width, req.Height, time.Now(), time.Now()).Scan(&elementID)
-	if err != nil {
+	now := time.Now()
+	element := models.BoardElement{
+		BoardID:   boardID,
+		Type:      req.Type,
+		Content:   req.Content,
+		PositionX: req.PositionX,
+		PositionY: req.PositionY,
+		Width:     req.Width,
+		Height:    req.Height,
+		Style:     req.Style,
+		Rotation:  req.Rotation,
+		Locked:    req.Locked,
+		Connector: req.Connector,
+		ParentID:  req.ParentID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = h.boards.CreateElement(c.Request.Context(), &element, userID.(int))
+	if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidConnectorRef})
+		return
+	} else if errors.Is(err, store.ErrInvalidParent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidParent})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания элемента доски"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Элемент успешно создан", "element_id": elementID})
+	// Оповещаем остальных участников доски
+	h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementCreated, Payload: element})
+
+	c.Header("ETag", etag(element.Version))
+	c.JSON(http.StatusCreated, gin.H{"message": "Элемент успешно создан", "element_id": element.ID, "version": element.Version})
 }
 
 // UpdateBoardElement обновляет элемент на доске
-func UpdateBoardElement(c *gin.Context) {
+func (h *BoardHandler) UpdateBoardElement(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -398,6 +311,10 @@ func UpdateBoardElement(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := models.ValidateConnector(req.Type, req.Connector); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -405,66 +322,154 @@ func UpdateBoardElement(c *gin.Context) {
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
-	defer db.Close()
 
-	// Проверяем, имеет ли пользователь права на редактирование доски
-	var creatorID int
-	query := `SELECT creator_id FROM boards WHERE id = $1`
-	err = db.QueryRow(query, boardID).Scan(&creatorID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	// Обновляем элемент, если он существует и принадлежит указанной доске.
+	// Заблокированный элемент и саму блокировку может менять только создатель доски;
+	// без поля locked блокировка остается прежней.
+	// Вложенные элементы рамки или группы сдвигаются вместе с ней.
+	element, moved, err := h.boards.ModifyElement(c.Request.Context(), boardID, elementID, store.UpdateElementOptions{AllowLocked: access.IsCreator, IfMatch: ifMatch, UserID: userID.(int)}, func(element *models.BoardElement) error {
+		element.Type = req.Type
+		element.Content = req.Content
+		element.PositionX = req.PositionX
+		element.PositionY = req.PositionY
+		element.Width = req.Width
+		element.Height = req.Height
+		element.Style = req.Style
+		element.Rotation = req.Rotation
+		if req.Locked != nil {
+			element.Locked = *req.Locked
+		}
+		element.Connector = req.Connector
+		element.ParentID = req.ParentID
+		element.UpdatedAt = time.Now()
+		return nil
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+		return
+	} else if errors.Is(err, store.ErrVersionMismatch) {
+		h.elementPreconditionFailed(c, boardID, elementID)
+		return
+	} else if errors.Is(err, store.ErrLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Элемент заблокирован, изменить его может только создатель доски"})
+		return
+	} else if errors.Is(err, store.ErrLockChange) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errLockChange})
+		return
+	} else if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidConnectorRef})
+		return
+	} else if errors.Is(err, store.ErrInvalidParent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidParent})
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления элемента"})
 		return
 	}
 
-	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
-	if creatorID != userID.(int) {
-		var canEdit bool
-		query = `SELECT can_edit FROM board_permissions WHERE board_id = $1 AND user_id = $2`
-		err = db.QueryRow(query, boardID, userID).Scan(&canEdit)
-		if err == sql.ErrNoRows || !canEdit {
-			c.JSON(http.StatusForbidden, gin.H{"error": "У вас нет прав на редактирование этой доски"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав доступа"})
-			return
-		}
+	// Оповещаем остальных участников доски
+	h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: element})
+	for _, child := range moved {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: child})
 	}
 
-	// Проверяем, существует ли элемент и принадлежит ли он указанной доске
-	var count int
-	query = `SELECT COUNT(*) FROM board_elements WHERE id = $1 AND board_id = $2`
-	err = db.QueryRow(query, elementID, boardID).Scan(&count)
+	c.Header("ETag", etag(element.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Элемент успешно обновлен", "version": element.Version, "moved_children": moved})
+}
+
+// DeleteBoardElement удаляет элемент с доски
+func (h *BoardHandler) DeleteBoardElement(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки элемента"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
 		return
 	}
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+
+	elementID, err := strconv.Atoi(c.Param("element_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID элемента"})
 		return
 	}
 
-	// Обновляем элемент
-	query = `UPDATE board_elements SET type = $1, content = $2, position_x = $3, position_y = $4, width = $5, height = $6, updated_at = $7 
-             WHERE id = $8 AND board_id = $9`
-	_, err = db.Exec(query, req.Type, req.Content, req.PositionX, req.PositionY, req.Width, req.Height, time.Now(), elementID, boardID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления элемента"})
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Элемент успешно обновлен"})
+	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	// Прикрепленные коннекторы по умолчанию удаляются вместе с элементом,
+	// с ?connectors=detach они остаются на доске со свободными концами
+	connectors := c.DefaultQuery("connectors", "delete")
+	if connectors != "delete" && connectors != "detach" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр connectors должен быть delete или detach"})
+		return
+	}
+	// Вложенные элементы по умолчанию переносятся на верхний уровень,
+	// с ?children=cascade они удаляются вместе с рамкой или группой
+	children := c.DefaultQuery("children", "orphan")
+	if children != "orphan" && children != "cascade" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметр children должен быть orphan или cascade"})
+		return
+	}
+
+	// Удаляем элемент, если он существует и принадлежит указанной доске.
+	// Заблокированный элемент может удалить только создатель доски.
+	deletion, err := h.boards.DeleteElement(c.Request.Context(), boardID, elementID, store.DeleteElementOptions{
+		AllowLocked:      access.IsCreator,
+		IfMatch:          ifMatch,
+		DetachConnectors: connectors == "detach",
+		CascadeChildren:  children == "cascade",
+		UserID:           userID.(int),
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+		return
+	} else if errors.Is(err, store.ErrVersionMismatch) {
+		h.elementPreconditionFailed(c, boardID, elementID)
+		return
+	} else if errors.Is(err, store.ErrLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Элемент заблокирован, удалить его может только создатель доски"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления элемента"})
+		return
+	}
+
+	// Оповещаем остальных участников доски
+	h.broadcastDeletion(boardID, elementID, deletion)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Элемент перемещен в корзину",
+		"deleted_children":    deletion.DeletedChildren,
+		"orphaned_children":   deletion.OrphanedChildren,
+		"deleted_connectors":  deletion.DeletedConnectors,
+		"detached_connectors": deletion.DetachedConnectors,
+	})
 }
 
-// DeleteBoardElement удаляет элемент с доски
-func DeleteBoardElement(c *gin.Context) {
+// ReorderBoardElement меняет порядок наложения элемента: на слой выше или ниже, на передний или задний план
+func (h *BoardHandler) ReorderBoardElement(c *gin.Context) {
 	boardID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
@@ -477,65 +482,135 @@ func DeleteBoardElement(c *gin.Context) {
 		return
 	}
 
+	var req models.ReorderElementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
 		return
 	}
 
-	db, err := database.ConnectDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка подключения к базе данных"})
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
 		return
 	}
-	defer db.Close()
 
-	// Проверяем, имеет ли пользователь права на редактирование доски
-	var creatorID int
-	query := `SELECT creator_id FROM boards WHERE id = $1`
-	err = db.QueryRow(query, boardID).Scan(&creatorID)
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+	changed, err := h.boards.ReorderElement(c.Request.Context(), boardID, elementID, userID.(int), req.Action, access.IsCreator)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+		return
+	} else if errors.Is(err, store.ErrLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Элемент заблокирован, изменить его может только создатель доски"})
 		return
 	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка изменения порядка элементов"})
 		return
 	}
 
-	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
-	if creatorID != userID.(int) {
-		var canEdit bool
-		query = `SELECT can_edit FROM board_permissions WHERE board_id = $1 AND user_id = $2`
-		err = db.QueryRow(query, boardID, userID).Scan(&canEdit)
-		if err == sql.ErrNoRows || !canEdit {
-			c.JSON(http.StatusForbidden, gin.H{"error": "У вас нет прав на редактирование этой доски"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки прав доступа"})
-			return
-		}
+	if len(changed) > 0 {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementsReordered, Payload: changed})
 	}
 
-	// Проверяем, существует ли элемент и принадлежит ли он указанной доске
-	var count int
-	query = `SELECT COUNT(*) FROM board_elements WHERE id = $1 AND board_id = $2`
-	err = db.QueryRow(query, elementID, boardID).Scan(&count)
+	c.JSON(http.StatusOK, gin.H{"message": "Порядок элементов обновлен", "changed": changed})
+}
+
+const (
+	errInvalidConnectorRef = "Коннектор может ссылаться только на существующие элементы этой доски, кроме других коннекторов"
+	errInvalidParent       = "Родителем элемента может быть только рамка или группа этой доски, без циклической вложенности"
+	errVersionMismatch     = "Данные изменились с тех пор, как вы их загрузили"
+	errLockChange          = "Блокировать и разблокировать элементы может только создатель доски"
+)
+
+// boardPreconditionFailed отвечает 412 с текущим состоянием доски, если её версия не совпала с If-Match
+func (h *BoardHandler) boardPreconditionFailed(c *gin.Context, boardID int) {
+	board, err := h.boards.GetBoard(c.Request.Context(), boardID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка проверки элемента"})
-		return
-	}
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch})
 		return
 	}
+	c.Header("ETag", etag(board.Version))
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch, "board": board})
+}
 
-	// Удаляем элемент
-	query = `DELETE FROM board_elements WHERE id = $1 AND board_id = $2`
-	_, err = db.Exec(query, elementID, boardID)
+// elementPreconditionFailed отвечает 412 с текущим состоянием элемента, если его версия не совпала с If-Match
+func (h *BoardHandler) elementPreconditionFailed(c *gin.Context, boardID, elementID int) {
+	element, err := h.boards.GetElement(c.Request.Context(), boardID, elementID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления элемента"})
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch})
 		return
 	}
+	c.Header("ETag", etag(element.Version))
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": errVersionMismatch, "element": element})
+}
+
+// broadcastDeletion оповещает участников доски об удаленном элементе и обо всех связанных с ним изменениях
+func (h *BoardHandler) broadcastDeletion(boardID, elementID int, deletion *store.ElementDeletion) {
+	deleted := append(append([]int{elementID}, deletion.DeletedChildren...), deletion.DeletedConnectors...)
+	for _, id := range deleted {
+		h.hub.Broadcast(boardID, realtime.Event{
+			Type:    realtime.EventElementDeleted,
+			Payload: realtime.DeletedElement{ID: id, BoardID: boardID},
+		})
+	}
+	for _, updated := range append(deletion.OrphanedChildren, deletion.DetachedConnectors...) {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: updated})
+	}
+}
+
+// requireViewAccess проверяет доступ на просмотр доски по тем же правилам, что и GetBoard:
+// создатель, публичная доска или выданное разрешение.
+// При отказе ответ клиенту уже отправлен и возвращается false.
+func (h *BoardHandler) requireViewAccess(c *gin.Context, boardID, userID int) bool {
+	access, err := h.boards.GetAccess(c.Request.Context(), boardID, userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return false
+	}
+	if err != nil || !access.CanView {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена или у вас нет доступа"})
+		return false
+	}
+	return true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Элемент успешно удален"})
+// requireEditAccess проверяет, что пользователь - создатель доски или имеет право can_edit.
+// При отказе ответ клиенту уже отправлен и возвращается false.
+func (h *BoardHandler) requireEditAccess(c *gin.Context, boardID, userID int) (store.Access, bool) {
+	access, err := h.boards.GetAccess(c.Request.Context(), boardID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return access, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return access, false
+	}
+
+	if !access.CanEdit {
+		c.JSON(http.StatusForbidden, gin.H{"error": "У вас нет прав на редактирование этой доски"})
+		return access, false
+	}
+	return access, true
+}
+
+// requireBoardCreator проверяет, что пользователь - создатель доски.
+// При отказе ответ клиенту уже отправлен и возвращается false.
+func (h *BoardHandler) requireBoardCreator(c *gin.Context, boardID, userID int) bool {
+	access, err := h.boards.GetAccess(c.Request.Context(), boardID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return false
+	}
+
+	if !access.IsCreator {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Только создатель доски может управлять доступом"})
+		return false
+	}
+	return true
 }
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBoardChanges возвращает изменения элементов доски после курсора ?since=<cursor>,
+// включая удаленные элементы. Курсор - версия доски из прошлого ответа или из ETag GetBoard;
+// since=0 возвращает все элементы.
+func (h *BoardHandler) GetBoardChanges(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный курсор"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	// Проверяем, имеет ли пользователь доступ к доске
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	changes, err := h.boards.ListChanges(c.Request.Context(), boardID, since)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		c.JSON(http.StatusGone, gin.H{"error": "Курсор устарел, загрузите доску заново"})
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения изменений доски"})
+		return
+	}
+
+	c.Header("ETag", etag(changes.Cursor))
+	c.JSON(http.StatusOK, changes)
+}
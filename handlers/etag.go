@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag возвращает ETag для версии доски или элемента
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion разбирает заголовок If-Match. Пустой заголовок и "*" означают любую версию (0).
+// При неверном заголовке ответ клиенту уже отправлен и возвращается false.
+func ifMatchVersion(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	value := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный заголовок If-Match, ожидается версия в кавычках"})
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified сообщает, совпадает ли ETag из If-None-Match с текущей версией
+func notModified(c *gin.Context, version int64) bool {
+	current := etag(version)
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == current || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
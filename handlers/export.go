@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportBoard отдает доску и все её элементы одним JSON-документом для переноса
+// между серверами и резервных копий
+func (h *BoardHandler) ExportBoard(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	board, err := h.boards.GetBoard(c.Request.Context(), boardID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return
+	}
+
+	elements, err := h.boards.ListElements(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения элементов доски"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%d.json"`, boardID))
+	c.JSON(http.StatusOK, models.NewBoardExport(board, elements))
+}
+
+// ImportBoard создает новую доску пользователя из документа экспорта.
+// Элементы получают новые ID, ссылки между ними переписываются.
+func (h *BoardHandler) ImportBoard(c *gin.Context) {
+	var req models.BoardExport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	publicSlug, err := randomToken(publicSlugSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания доски"})
+		return
+	}
+
+	// Импортированная доска всегда приватная, доступ к ней выдается заново
+	now := time.Now()
+	board := models.Board{
+		Title:       req.Board.Title,
+		Description: req.Board.Description,
+		CreatorID:   userID.(int),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	ids, err := h.boards.ImportBoard(c.Request.Context(), &board, publicSlug, req.BoardElements())
+	if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errInvalidConnectorRef})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка импорта доски"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Доска успешно импортирована", "board_id": board.ID, "element_ids": ids})
+}
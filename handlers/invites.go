@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBoardInvite создает ссылку-приглашение на доску
+func (h *BoardHandler) CreateBoardInvite(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	var req models.CreateBoardInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Срок действия приглашения должен быть в будущем"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания приглашения"})
+		return
+	}
+
+	invite := models.BoardInvite{
+		BoardID:   boardID,
+		Token:     token,
+		Role:      req.Role,
+		ExpiresAt: req.ExpiresAt,
+		MaxUses:   req.MaxUses,
+		CreatedBy: userID.(int),
+		CreatedAt: time.Now(),
+	}
+	if err := h.boards.CreateInvite(c.Request.Context(), &invite); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания приглашения"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// GetBoardInvites возвращает действующие приглашения на доску
+func (h *BoardHandler) GetBoardInvites(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	// Истекшие и исчерпанные приглашения не показываем
+	invites, err := h.boards.ListActiveInvites(c.Request.Context(), boardID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения приглашений"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeBoardInvite отзывает приглашение на доску
+func (h *BoardHandler) RevokeBoardInvite(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	inviteID, err := strconv.Atoi(c.Param("invite_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID приглашения"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	err = h.boards.DeleteInvite(c.Request.Context(), boardID, inviteID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Приглашение не найдено"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отзыва приглашения"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Приглашение успешно отозвано"})
+}
+
+// AcceptBoardInvite выдает текущему пользователю доступ к доске по приглашению
+func (h *BoardHandler) AcceptBoardInvite(c *gin.Context) {
+	token := c.Param("token")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	acceptance, err := h.boards.AcceptInvite(c.Request.Context(), token, userID.(int), time.Now())
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Приглашение не найдено"})
+		return
+	case errors.Is(err, store.ErrInviteExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Срок действия приглашения истек"})
+		return
+	case errors.Is(err, store.ErrInviteUsedUp):
+		c.JSON(http.StatusGone, gin.H{"error": "Приглашение больше недействительно"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выдачи доступа"})
+		return
+	}
+
+	if acceptance.IsCreator {
+		c.JSON(http.StatusOK, gin.H{"message": "Вы уже являетесь создателем доски", "board_id": acceptance.BoardID})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Доступ к доске получен", "board_id": acceptance.BoardID})
+}
+
+// randomToken возвращает случайную строку, пригодную для использования в URL
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UndoBoardOperation отменяет последнюю операцию пользователя над элементами доски
+func (h *BoardHandler) UndoBoardOperation(c *gin.Context) {
+	h.replayBoardOperation(c, true)
+}
+
+// RedoBoardOperation повторяет последнюю отмененную операцию пользователя над элементами доски
+func (h *BoardHandler) RedoBoardOperation(c *gin.Context) {
+	h.replayBoardOperation(c, false)
+}
+
+func (h *BoardHandler) replayBoardOperation(c *gin.Context, undo bool) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if _, ok := h.requireEditAccess(c, boardID, userID.(int)); !ok {
+		return
+	}
+
+	var replay *models.OperationReplay
+	if undo {
+		replay, err = h.boards.UndoOperation(c.Request.Context(), boardID, userID.(int))
+	} else {
+		replay, err = h.boards.RedoOperation(c.Request.Context(), boardID, userID.(int))
+	}
+	if errors.Is(err, store.ErrNoOperation) {
+		message := "Нет операций для отмены"
+		if !undo {
+			message = "Нет операций для повтора"
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": message})
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Операция ссылается на элементы, которых уже нет на доске"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка применения операции"})
+		return
+	}
+
+	// Для остальных участников отмена выглядит как обычные изменения элементов
+	for _, element := range replay.Created {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementCreated, Payload: element})
+	}
+	for _, element := range replay.Updated {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: element})
+	}
+	for _, id := range replay.Deleted {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementDeleted, Payload: realtime.DeletedElement{ID: id, BoardID: boardID}})
+	}
+
+	c.Header("ETag", etag(replay.Version))
+	c.JSON(http.StatusOK, replay)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"micromiro/mail"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Сколько ждать сохранения токена сброса и отправки письма
+const mailTimeout = time.Minute
+
+// Сколько действующих ссылок сброса может быть у пользователя одновременно.
+// Следующие запросы до истечения или использования ссылок письма не отправляют.
+const maxPasswordResets = 3
+
+// ForgotPassword отправляет на email ссылку сброса пароля. Ответ одинаков для известных
+// и неизвестных адресов, а токен создается и письмо отправляется в фоне, чтобы ни ответ,
+// ни время ответа не выдавали, зарегистрирован ли адрес. По той же причине ошибки
+// после поиска пользователя только пишутся в лог.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.users.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if user != nil {
+		go h.requestPasswordReset(user)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Если адрес зарегистрирован, на него отправлено письмо со ссылкой для сброса пароля"})
+}
+
+// ResetPassword меняет пароль по токену из письма. Токен действует один раз,
+// после сброса все сессии пользователя завершаются.
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка хеширования пароля"})
+		return
+	}
+
+	userID, err := h.tokens.ResetPassword(c.Request.Context(), hashToken(req.Token), string(hashedPassword), time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ссылка для сброса пароля недействительна или устарела"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	// Доски, открытые до сброса пароля, тоже закрываются: все сессии уже завершены
+	h.hub.DisconnectUser(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен. Войдите с новым паролем"})
+}
+
+// requestPasswordReset создает токен сброса и отправляет письмо. Вызывается в фоне,
+// поэтому ошибки только пишутся в лог. Если у пользователя уже много действующих
+// ссылок, письмо не отправляется.
+func (h *AuthHandler) requestPasswordReset(user *models.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	logger := h.logger.WithFields(log.Fields{"user_id": user.ID})
+	token, err := randomToken(32)
+	if err != nil {
+		logger.Errorf("Не удалось создать токен сброса пароля: %v", err)
+		return
+	}
+	now := time.Now()
+	err = h.tokens.CreatePasswordReset(ctx, user.ID, hashToken(token), now.Add(h.config.PasswordResetTTL), now, maxPasswordResets)
+	if errors.Is(err, store.ErrLimitExceeded) {
+		logger.Warn("Слишком много запросов сброса пароля, письмо не отправлено")
+		return
+	} else if err != nil {
+		logger.Errorf("Не удалось сохранить токен сброса пароля: %v", err)
+		return
+	}
+	h.sendPasswordReset(ctx, user, token)
+}
+
+// sendPasswordReset отправляет письмо со ссылкой сброса пароля, ошибки пишутся в лог
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, user *models.User, token string) {
+	link := fmt.Sprintf("%s/reset-password?token=%s", h.config.AppURL, url.QueryEscape(token))
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля MicroMiro",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Чтобы задать новый пароль, откройте ссылку:\n%s\n\n"+
+			"Ссылка действует %d мин. и только один раз. Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n",
+			user.Username, link, int(h.config.PasswordResetTTL.Minutes())),
+	}
+	if err := h.mailer.Send(ctx, msg); err != nil {
+		h.logger.WithFields(log.Fields{"user_id": user.ID}).Errorf("Не удалось отправить письмо сброса пароля: %v", err)
+	}
+}
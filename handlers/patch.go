@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// mergePatchContentType - тип содержимого документа JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// invalidPatchError - патч нельзя применить или результат не прошел проверку
+type invalidPatchError struct {
+	message string
+}
+
+func (e *invalidPatchError) Error() string {
+	return e.message
+}
+
+// PatchBoardElement частично обновляет элемент документом JSON Merge Patch:
+// меняются только переданные поля, null удаляет значение.
+// Результат проверяется целиком перед сохранением.
+func (h *BoardHandler) PatchBoardElement(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	elementID, err := strconv.Atoi(c.Param("element_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID элемента"})
+		return
+	}
+
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Ожидается Content-Type " + mergePatchContentType})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Патч должен быть JSON-объектом"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	ifMatch, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	// Проверяем, является ли пользователь создателем доски или имеет права на редактирование
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	// Патч применяется к текущему состоянию элемента в той же транзакции, что и сохранение
+	element, moved, err := h.boards.ModifyElement(c.Request.Context(), boardID, elementID, store.UpdateElementOptions{AllowLocked: access.IsCreator, IfMatch: ifMatch, UserID: userID.(int)}, func(element *models.BoardElement) error {
+		return applyElementPatch(element, patch)
+	})
+	var patchErr *invalidPatchError
+	if errors.As(err, &patchErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": patchErr.message})
+		return
+	} else if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден или не принадлежит указанной доске"})
+		return
+	} else if errors.Is(err, store.ErrVersionMismatch) {
+		h.elementPreconditionFailed(c, boardID, elementID)
+		return
+	} else if errors.Is(err, store.ErrLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Элемент заблокирован, изменить его может только создатель доски"})
+		return
+	} else if errors.Is(err, store.ErrLockChange) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errLockChange})
+		return
+	} else if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errInvalidConnectorRef})
+		return
+	} else if errors.Is(err, store.ErrInvalidParent) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": errInvalidParent})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления элемента"})
+		return
+	}
+
+	// Оповещаем остальных участников доски
+	h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: element})
+	for _, child := range moved {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementUpdated, Payload: child})
+	}
+
+	c.Header("ETag", etag(element.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Элемент успешно обновлен", "element": element, "moved_children": moved})
+}
+
+// applyElementPatch применяет патч к редактируемым полям элемента и проверяет результат
+func applyElementPatch(element *models.BoardElement, patch map[string]interface{}) error {
+	current, err := json.Marshal(models.UpdateBoardElementRequest{
+		Type:      element.Type,
+		Content:   element.Content,
+		PositionX: element.PositionX,
+		PositionY: element.PositionY,
+		Width:     element.Width,
+		Height:    element.Height,
+		Style:     element.Style,
+		Rotation:  element.Rotation,
+		Locked:    &element.Locked,
+		Connector: element.Connector,
+		ParentID:  element.ParentID,
+	})
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	if err := json.Unmarshal(current, &document); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return err
+	}
+
+	// Поля, которых нет в запросе на обновление (id, z_index и т.п.), патчем не меняются
+	var req models.UpdateBoardElementRequest
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return &invalidPatchError{message: err.Error()}
+	}
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		return &invalidPatchError{message: err.Error()}
+	}
+	if req.Type == "" {
+		return &invalidPatchError{message: "Тип элемента не может быть пустым"}
+	}
+	if req.Width < 0 || req.Height < 0 {
+		return &invalidPatchError{message: "Размеры элемента не могут быть отрицательными"}
+	}
+	if err := models.ValidateConnector(req.Type, req.Connector); err != nil {
+		return &invalidPatchError{message: err.Error()}
+	}
+
+	element.Type = req.Type
+	element.Content = req.Content
+	element.PositionX = req.PositionX
+	element.PositionY = req.PositionY
+	element.Width = req.Width
+	element.Height = req.Height
+	element.Style = req.Style
+	element.Rotation = req.Rotation
+	// null в патче оставляет блокировку прежней
+	if req.Locked != nil {
+		element.Locked = *req.Locked
+	}
+	element.Connector = req.Connector
+	element.ParentID = req.ParentID
+	element.UpdatedAt = time.Now()
+	return nil
+}
+
+// mergePatch применяет патч к документу по правилам RFC 7396
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"micromiro/models"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		patch  string
+		want   string
+	}{
+		{"замена значения", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"новое поле", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null удаляет поле", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"null для отсутствующего поля", `{"a":"b"}`, `{"c":null}`, `{"a":"b"}`},
+		{"вложенный объект сливается", `{"a":{"b":1,"c":2}}`, `{"a":{"b":3}}`, `{"a":{"b":3,"c":2}}`},
+		{"null во вложенном объекте", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null}}`, `{"a":{"c":2}}`},
+		{"массив заменяется целиком", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"объект вместо скаляра", `{"a":"b"}`, `{"a":{"c":null,"d":1}}`, `{"a":{"d":1}}`},
+		{"скаляр вместо объекта", `{"a":{"b":1}}`, `{"a":1}`, `{"a":1}`},
+		{"пустой патч", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target, patch, want interface{}
+			mustUnmarshal(t, tt.target, &target)
+			mustUnmarshal(t, tt.patch, &patch)
+			mustUnmarshal(t, tt.want, &want)
+
+			if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch(%s, %s) = %v, ожидалось %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyElementPatch(t *testing.T) {
+	strokeWidth := 2.0
+	parentID := 7
+	base := models.BoardElement{
+		ID:        1,
+		BoardID:   1,
+		Type:      "rectangle",
+		Content:   "текст",
+		PositionX: 10,
+		PositionY: 20,
+		Width:     100,
+		Height:    50,
+		Style:     models.ElementStyle{Fill: "#ffffff", StrokeWidth: &strokeWidth},
+		ZIndex:    3,
+		Locked:    true,
+		ParentID:  &parentID,
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		invalid bool
+		check   func(t *testing.T, element models.BoardElement)
+	}{
+		{
+			name:  "меняются только переданные поля",
+			patch: `{"position_x":15,"content":"новый"}`,
+			check: func(t *testing.T, element models.BoardElement) {
+				if element.PositionX != 15 || element.Content != "новый" {
+					t.Errorf("поля не обновлены: %+v", element)
+				}
+				if element.PositionY != 20 || element.Width != 100 || element.Style.Fill != "#ffffff" {
+					t.Errorf("изменились поля, которых нет в патче: %+v", element)
+				}
+			},
+		},
+		{
+			name:  "null удаляет поле стиля",
+			patch: `{"style":{"fill":null}}`,
+			check: func(t *testing.T, element models.BoardElement) {
+				if element.Style.Fill != "" {
+					t.Errorf("fill = %q, ожидалось пустое значение", element.Style.Fill)
+				}
+				if element.Style.StrokeWidth == nil || *element.Style.StrokeWidth != 2 {
+					t.Errorf("stroke_width потерян при слиянии стиля")
+				}
+			},
+		},
+		{
+			name:  "null снимает родителя",
+			patch: `{"parent_id":null}`,
+			check: func(t *testing.T, element models.BoardElement) {
+				if element.ParentID != nil {
+					t.Errorf("parent_id = %d, ожидалось null", *element.ParentID)
+				}
+			},
+		},
+		{
+			name:  "null оставляет блокировку",
+			patch: `{"locked":null}`,
+			check: func(t *testing.T, element models.BoardElement) {
+				if !element.Locked {
+					t.Errorf("блокировка снята патчем с null")
+				}
+			},
+		},
+		{
+			name:  "блокировка снимается явно",
+			patch: `{"locked":false}`,
+			check: func(t *testing.T, element models.BoardElement) {
+				if element.Locked {
+					t.Errorf("блокировка не снята")
+				}
+			},
+		},
+		{name: "неизвестное поле", patch: `{"z_index":10}`, invalid: true},
+		{name: "пустой тип", patch: `{"type":null}`, invalid: true},
+		{name: "отрицательный размер", patch: `{"width":-1}`, invalid: true},
+		{name: "поворот вне диапазона", patch: `{"rotation":400}`, invalid: true},
+		{name: "неверный цвет", patch: `{"style":{"fill":"red;"}}`, invalid: true},
+		{name: "коннектор у прямоугольника", patch: `{"connector":{"source":{"x":0,"y":0},"target":{"x":1,"y":1}}}`, invalid: true},
+		{name: "тип connector без коннектора", patch: `{"type":"connector"}`, invalid: true},
+		{name: "неверный тип значения", patch: `{"position_x":"1"}`, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch map[string]interface{}
+			mustUnmarshal(t, tt.patch, &patch)
+
+			element := base
+			err := applyElementPatch(&element, patch)
+
+			var patchErr *invalidPatchError
+			if tt.invalid {
+				if !errors.As(err, &patchErr) {
+					t.Fatalf("ожидалась ошибка invalidPatchError, получено %v", err)
+				}
+				if element.PositionX != base.PositionX || element.Type != base.Type || element.Locked != base.Locked {
+					t.Errorf("отклоненный патч изменил элемент: %+v", element)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyElementPatch: %v", err)
+			}
+			if element.ID != base.ID || element.ZIndex != base.ZIndex {
+				t.Errorf("патч изменил поля вне запроса на обновление: %+v", element)
+			}
+			tt.check(t, element)
+		})
+	}
+}
+
+func mustUnmarshal(t *testing.T, data string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", data, err)
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBoardPermissions возвращает список пользователей с доступом к доске
+func (h *BoardHandler) GetBoardPermissions(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	permissions, err := h.boards.ListPermissions(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения разрешений доски"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// GrantBoardPermission выдает пользователю доступ к доске по email или имени
+func (h *BoardHandler) GrantBoardPermission(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	var req models.GrantBoardPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Email == "") == (req.Username == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите email или имя пользователя"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	// Ищем пользователя, которому выдается доступ
+	user, err := h.users.FindUser(c.Request.Context(), req.Email, req.Username)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка поиска пользователя"})
+		return
+	}
+
+	if user.ID == userID.(int) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Создатель доски уже имеет полный доступ"})
+		return
+	}
+
+	now := time.Now()
+	permission := models.BoardPermission{
+		BoardID:   boardID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		CanEdit:   req.CanEdit,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = h.boards.GrantPermission(c.Request.Context(), &permission)
+	if errors.Is(err, store.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "У пользователя уже есть доступ к доске"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка выдачи доступа"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, permission)
+}
+
+// UpdateBoardPermission меняет право пользователя на редактирование доски
+func (h *BoardHandler) UpdateBoardPermission(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	targetUserID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID пользователя"})
+		return
+	}
+
+	var req models.UpdateBoardPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	err = h.boards.UpdatePermission(c.Request.Context(), boardID, targetUserID, *req.CanEdit)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "У пользователя нет доступа к доске"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления доступа"})
+		return
+	}
+	// Права проверяются при подключении к доске, поэтому открытые подключения пользователя закрываются
+	h.hub.DisconnectBoardUser(boardID, targetUserID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Доступ успешно обновлен"})
+}
+
+// RevokeBoardPermission отзывает доступ пользователя к доске
+func (h *BoardHandler) RevokeBoardPermission(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	targetUserID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID пользователя"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	err = h.boards.RevokePermission(c.Request.Context(), boardID, targetUserID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "У пользователя нет доступа к доске"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отзыва доступа"})
+		return
+	}
+	// Доступ проверяется при подключении к доске, поэтому открытые подключения пользователя закрываются
+	h.hub.DisconnectBoardUser(boardID, targetUserID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Доступ успешно отозван"})
+}
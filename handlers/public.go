@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Длина случайной части публичной ссылки в байтах
+const publicSlugSize = 16
+
+// GetPublicBoard возвращает публичную доску и её элементы без авторизации
+func (h *BoardHandler) GetPublicBoard(c *gin.Context) {
+	slug := c.Param("slug")
+
+	// Непубличные доски неотличимы от несуществующих
+	board, err := h.boards.GetPublicBoard(c.Request.Context(), slug)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return
+	}
+
+	c.Header("ETag", etag(board.Version))
+	if notModified(c, board.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	elements, err := h.boards.ListElements(c.Request.Context(), board.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения элементов доски"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"board": board, "elements": elements, "hierarchy": models.BuildHierarchy(elements), "read_only": true})
+}
+
+// GetBoardPublicLink возвращает текущую публичную ссылку доски
+func (h *BoardHandler) GetBoardPublicLink(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	link, err := h.boards.GetPublicLink(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return
+	}
+
+	// У досок, созданных до появления публичных ссылок, ссылки нет - создаем её сейчас
+	if link.Slug == nil {
+		slug, err := randomToken(publicSlugSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки"})
+			return
+		}
+		link, err = h.boards.EnsurePublicSlug(c.Request.Context(), boardID, slug)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// RotateBoardPublicLink заменяет публичную ссылку доски на новую.
+// Старая ссылка сразу перестает работать.
+func (h *BoardHandler) RotateBoardPublicLink(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireBoardCreator(c, boardID, userID.(int)) {
+		return
+	}
+
+	slug, err := randomToken(publicSlugSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания ссылки"})
+		return
+	}
+
+	link, err := h.boards.SetPublicSlug(c.Request.Context(), boardID, slug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка обновления ссылки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"micromiro/realtime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// CORS открыт для всех источников, поэтому WebSocket тоже
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// BoardWebSocket подключает пользователя к потоку изменений доски
+func (h *BoardHandler) BoardWebSocket(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	// Проверяем доступ по тем же правилам, что и GetBoard
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrader уже отправил клиенту ответ с ошибкой
+		return
+	}
+
+	email, _ := c.Get("email")
+	sessionID, _ := c.Get("session_id")
+	realtime.NewClient(h.hub, conn, boardID, userID.(int), email.(string), sessionID.(string)).Serve()
+}
+
+// GetBoardPresence возвращает пользователей, которые сейчас находятся на доске
+func (h *BoardHandler) GetBoardPresence(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	c.JSON(http.StatusOK, h.hub.Presence(boardID))
+}
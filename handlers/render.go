@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"micromiro/models"
+	"micromiro/render"
+	"micromiro/store"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Наибольшая сторона области отрисовки в единицах холста
+const maxRenderSize = 100000
+
+// Пределы масштаба картинки
+const (
+	minRenderScale = 0.1
+	maxRenderScale = 8
+)
+
+// Доски с большим числом элементов выгружаются в PNG и PDF фоновой задачей
+const syncExportElements = 300
+
+// Сколько незавершенных фоновых выгрузок может быть у пользователя
+const maxActiveExports = 5
+
+// PNG больше этого числа пикселей (около 16 МБ в памяти) тоже рисуется фоновой задачей,
+// чтобы параллельные запросы не держали в памяти большие картинки
+const syncExportPixels = 4_000_000
+
+// Цвет фона картинки - только hex, чтобы его можно было вставить в SVG как есть
+var backgroundColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+var exportContentTypes = map[string]string{
+	models.ExportFormatPNG: "image/png",
+	models.ExportFormatPDF: "application/pdf",
+}
+
+// RenderBoardSVG рисует элементы доски в SVG. По умолчанию рисуется вся доска,
+// ?frame=<id> ограничивает картинку рамкой, ?x=&y=&width=&height= - областью холста.
+func (h *BoardHandler) RenderBoardSVG(c *gin.Context) {
+	boardID, _, params, ok := h.boardImageRequest(c)
+	if !ok {
+		return
+	}
+
+	board, ok := h.imageBoard(c, boardID)
+	if !ok {
+		return
+	}
+
+	elements, err := h.boards.ListElements(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения элементов доски"})
+		return
+	}
+
+	var svg bytes.Buffer
+	if err := h.renderer.SVG(&svg, elements, params); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(board.Version))
+	c.Data(http.StatusOK, "image/svg+xml", svg.Bytes())
+}
+
+// ExportBoardPNG рисует доску в PNG. Параметры области и фона те же, что у render.svg,
+// ?scale= задает число пикселей на единицу холста.
+func (h *BoardHandler) ExportBoardPNG(c *gin.Context) {
+	h.exportBoardImage(c, models.ExportFormatPNG)
+}
+
+// ExportBoardPDF рисует доску в PDF по странице на рамку
+func (h *BoardHandler) ExportBoardPDF(c *gin.Context) {
+	h.exportBoardImage(c, models.ExportFormatPDF)
+}
+
+// exportBoardImage отдает картинку доски сразу, а для большой доски или большой картинки
+// ставит выгрузку в очередь и отвечает 202 с адресом, по которому можно следить за её состоянием
+func (h *BoardHandler) exportBoardImage(c *gin.Context, format string) {
+	boardID, userID, params, ok := h.boardImageRequest(c)
+	if !ok {
+		return
+	}
+
+	board, ok := h.imageBoard(c, boardID)
+	if !ok {
+		return
+	}
+
+	elements, err := h.boards.ListElements(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения элементов доски"})
+		return
+	}
+
+	queued := len(elements) > syncExportElements
+	if format == models.ExportFormatPNG && !queued {
+		width, height, err := render.ImageSize(elements, params)
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+		queued = width*height > syncExportPixels
+	}
+	if queued {
+		job := &models.ExportJob{BoardID: boardID, UserID: userID, Format: format, Params: params, CreatedAt: time.Now()}
+		err := h.boards.CreateExportJob(c.Request.Context(), job, board.Version, maxActiveExports)
+		if errors.Is(err, store.ErrLimitExceeded) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много незавершенных выгрузок, дождитесь их окончания"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка создания выгрузки"})
+			return
+		}
+		if job.Status == models.ExportJobDone {
+			job.DownloadURL = fmt.Sprintf("/api/v1/protected/boards/%d/exports/%d/file", job.BoardID, job.ID)
+		}
+		c.Header("Location", fmt.Sprintf("/api/v1/protected/boards/%d/exports/%d", boardID, job.ID))
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := h.renderer.Export(&out, format, elements, params); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(board.Version))
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="board-%d.%s"`, boardID, format))
+	c.Data(http.StatusOK, exportContentTypes[format], out.Bytes())
+}
+
+// GetExportJob возвращает состояние фоновой выгрузки, поставленной текущим пользователем
+func (h *BoardHandler) GetExportJob(c *gin.Context) {
+	job, ok := h.exportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status == models.ExportJobDone {
+		job.DownloadURL = fmt.Sprintf("/api/v1/protected/boards/%d/exports/%d/file", job.BoardID, job.ID)
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExportJob отдает файл готовой выгрузки
+func (h *BoardHandler) DownloadExportJob(c *gin.Context) {
+	job, ok := h.exportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.ExportJobDone {
+		c.JSON(http.StatusConflict, gin.H{"error": "Выгрузка еще не готова", "status": job.Status})
+		return
+	}
+
+	result, err := h.boards.GetExportResult(c.Request.Context(), job.BoardID, job.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Выгрузка не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения выгрузки"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%d.%s"`, job.BoardID, job.Format))
+	c.Data(http.StatusOK, exportContentTypes[job.Format], result)
+}
+
+// exportJob находит выгрузку из пути запроса. Чужие выгрузки неотличимы от несуществующих.
+// При ошибке отвечает и возвращает false.
+func (h *BoardHandler) exportJob(c *gin.Context) (*models.ExportJob, bool) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return nil, false
+	}
+
+	jobID, err := strconv.Atoi(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID выгрузки"})
+		return nil, false
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return nil, false
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return nil, false
+	}
+
+	job, err := h.boards.GetExportJob(c.Request.Context(), boardID, jobID)
+	if errors.Is(err, store.ErrNotFound) || err == nil && job.UserID != userID.(int) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Выгрузка не найдена"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения выгрузки"})
+		return nil, false
+	}
+	return job, true
+}
+
+// boardImageRequest разбирает ID доски и параметры картинки и проверяет право просмотра.
+// При ошибке отвечает и возвращает false.
+func (h *BoardHandler) boardImageRequest(c *gin.Context) (int, int, models.RenderParams, bool) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return 0, 0, models.RenderParams{}, false
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return 0, 0, models.RenderParams{}, false
+	}
+
+	params, ok := renderParams(c)
+	if !ok {
+		return 0, 0, models.RenderParams{}, false
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return 0, 0, models.RenderParams{}, false
+	}
+	return boardID, userID.(int), params, true
+}
+
+// imageBoard возвращает доску для отрисовки. Картинка зависит только от элементов,
+// поэтому версия доски годится для кеширования: при совпадении If-None-Match отвечает 304.
+// Если ответ уже отправлен, возвращает false.
+func (h *BoardHandler) imageBoard(c *gin.Context, boardID int) (*models.Board, bool) {
+	board, err := h.boards.GetBoard(c.Request.Context(), boardID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения доски"})
+		return nil, false
+	}
+
+	if notModified(c, board.Version) {
+		c.Header("ETag", etag(board.Version))
+		c.Status(http.StatusNotModified)
+		return nil, false
+	}
+	return board, true
+}
+
+// renderError отвечает на ошибку отрисовки доски
+func renderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, render.ErrFrameNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Рамка не найдена на доске"})
+	case errors.Is(err, render.ErrImageTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Картинка слишком большая, уменьшите масштаб или область"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отрисовки доски"})
+	}
+}
+
+// renderParams разбирает параметры выбора области, масштаба и фона картинки.
+// При ошибке отвечает 400 и возвращает false.
+func renderParams(c *gin.Context) (models.RenderParams, bool) {
+	var params models.RenderParams
+
+	if frame := c.Query("frame"); frame != "" {
+		frameID, err := strconv.Atoi(frame)
+		if err != nil || frameID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID рамки"})
+			return params, false
+		}
+		params.FrameID = frameID
+	}
+
+	// Область задается всеми четырьмя параметрами сразу
+	region := []string{c.Query("x"), c.Query("y"), c.Query("width"), c.Query("height")}
+	if region[0] != "" || region[1] != "" || region[2] != "" || region[3] != "" {
+		values := make([]float64, len(region))
+		for i, raw := range region {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Область задается числами x, y, width и height"})
+				return params, false
+			}
+			values[i] = v
+		}
+		if values[2] <= 0 || values[3] <= 0 || values[2] > maxRenderSize || values[3] > maxRenderSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный размер области"})
+			return params, false
+		}
+		params.Region = &models.RenderRegion{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+	}
+
+	if scale := c.Query("scale"); scale != "" {
+		v, err := strconv.ParseFloat(scale, 64)
+		if err != nil || !(v >= minRenderScale && v <= maxRenderScale) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Масштаб задается числом от %g до %g", minRenderScale, float64(maxRenderScale))})
+			return params, false
+		}
+		params.Scale = v
+	}
+
+	if background := c.Query("background"); background != "" {
+		if !backgroundColor.MatchString(background) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Цвет фона задается в формате #rgb или #rrggbb"})
+			return params, false
+		}
+		params.Background = background
+	}
+
+	return params, true
+}
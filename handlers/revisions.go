@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/models"
+	"micromiro/realtime"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBoardRevisions возвращает историю ревизий доски, новые первыми
+func (h *BoardHandler) GetBoardRevisions(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	revisions, err := h.boards.ListRevisions(c.Request.Context(), boardID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения ревизий доски"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// CreateBoardRevision сохраняет текущее состояние доски как именованную ревизию
+func (h *BoardHandler) CreateBoardRevision(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	var req models.CreateBoardRevisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if _, ok := h.requireEditAccess(c, boardID, userID.(int)); !ok {
+		return
+	}
+
+	author := userID.(int)
+	revision, err := h.boards.CreateRevision(c.Request.Context(), boardID, &author, models.RevisionManual, req.Label)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка сохранения ревизии"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, revision)
+}
+
+// GetBoardRevision возвращает ревизию с элементами доски на момент её сохранения.
+// Ревизия доступна только для чтения.
+func (h *BoardHandler) GetBoardRevision(c *gin.Context) {
+	boardID, revisionID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	revision, elements, err := h.boards.GetRevision(c.Request.Context(), boardID, revisionID)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ревизия не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения ревизии"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revision": revision, "elements": elements, "hierarchy": models.BuildHierarchy(elements), "read_only": true})
+}
+
+// RestoreBoardRevision делает элементы ревизии текущими элементами доски.
+// Состояние до восстановления сохраняется отдельной ревизией, так что восстановление можно отменить.
+func (h *BoardHandler) RestoreBoardRevision(c *gin.Context) {
+	boardID, revisionID, ok := revisionParams(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	access, ok := h.requireEditAccess(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	before, version, err := h.boards.RestoreRevision(c.Request.Context(), boardID, revisionID, userID.(int), access.IsCreator)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ревизия не найдена"})
+		return
+	} else if errors.Is(err, store.ErrLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Восстановление затрагивает заблокированные элементы, выполнить его может только создатель доски"})
+		return
+	} else if errors.Is(err, store.ErrInvalidReference) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ревизия ссылается на элементы, которые нельзя восстановить"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка восстановления ревизии"})
+		return
+	}
+
+	h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventBoardRestored, Payload: realtime.RestoredBoard{RevisionID: revisionID, Version: version}})
+
+	c.Header("ETag", etag(version))
+	c.JSON(http.StatusOK, gin.H{"message": "Ревизия восстановлена", "version": version, "previous_revision": before})
+}
+
+func revisionParams(c *gin.Context) (int, int, bool) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return 0, 0, false
+	}
+	revisionID, err := strconv.Atoi(c.Param("revision_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID ревизии"})
+		return 0, 0, false
+	}
+	return boardID, revisionID, true
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/store"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSessions возвращает действующие сессии текущего пользователя: устройство, адрес,
+// время входа и последнего использования. Сессия запроса помечена current.
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, sessionID, ok := requestSession(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.tokens.ListSessions(c.Request.Context(), userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения сессий"})
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == sessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession завершает одну из сессий текущего пользователя, например на потерянном устройстве
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, _, ok := requestSession(c)
+	if !ok {
+		return
+	}
+
+	sessionID := c.Param("session_id")
+	err := h.tokens.RevokeSession(c.Request.Context(), userID, sessionID, time.Now())
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Сессия не найдена"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка завершения сессии"})
+		return
+	}
+	h.hub.DisconnectSessions(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
+
+// truncate обрезает строку до limit байт, не разрывая символы
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
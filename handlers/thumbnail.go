@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"micromiro/models"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// thumbnailURL возвращает адрес превью доски. Версия доски в адресе меняет его
+// при каждом изменении, чтобы список досок не показывал устаревшую картинку из кеша.
+func thumbnailURL(board models.Board) string {
+	return fmt.Sprintf("/api/v1/protected/boards/%d/thumbnail.png?v=%d", board.ID, board.Version)
+}
+
+// GetBoardThumbnail отдает превью доски для списка досок. Пока превью не нарисовано,
+// например у новой доски, отдается заглушка пустой доски.
+func (h *BoardHandler) GetBoardThumbnail(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	if !h.requireViewAccess(c, boardID, userID.(int)) {
+		return
+	}
+
+	// Заглушка помечается версией 0, которой не бывает у досок
+	image, version, err := h.boards.GetThumbnail(c.Request.Context(), boardID)
+	if errors.Is(err, store.ErrNotFound) {
+		var placeholder bytes.Buffer
+		if err := h.renderer.Thumbnail(&placeholder, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка отрисовки превью доски"})
+			return
+		}
+		image, version = placeholder.Bytes(), 0
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения превью доски"})
+		return
+	}
+
+	// Превью той версии, что указана в адресе, больше не изменится. Иначе картинка
+	// еще догоняет доску, и браузер должен переспросить её по ETag.
+	if c.Query("v") == strconv.FormatInt(version, 10) {
+		c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	c.Header("ETag", etag(version))
+	if notModified(c, version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", image)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"micromiro/realtime"
+	"micromiro/store"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash возвращает корзину пользователя: удаленные доски и элементы
+func (h *BoardHandler) GetTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	trash, err := h.boards.ListTrash(c.Request.Context(), userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка получения корзины"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// RestoreTrashedBoard возвращает доску из корзины. Доступно только создателю доски.
+func (h *BoardHandler) RestoreTrashedBoard(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	board, err := h.boards.RestoreBoard(c.Request.Context(), boardID, userID.(int))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена в корзине"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка восстановления доски"})
+		return
+	}
+
+	board.ThumbnailURL = thumbnailURL(*board)
+
+	c.Header("ETag", etag(board.Version))
+	c.JSON(http.StatusOK, board)
+}
+
+// PurgeTrashedBoard окончательно удаляет доску из корзины. Доступно только создателю доски.
+func (h *BoardHandler) PurgeTrashedBoard(c *gin.Context) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	err = h.boards.PurgeBoard(c.Request.Context(), boardID, userID.(int))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Доска не найдена в корзине"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления доски"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Доска удалена окончательно"})
+}
+
+// RestoreTrashedElement возвращает элемент из корзины вместе с элементами, удаленными с ним
+func (h *BoardHandler) RestoreTrashedElement(c *gin.Context) {
+	boardID, elementID, ok := trashedElementParams(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	deletedBy, ok := h.trashedElementOwner(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	restored, err := h.boards.RestoreElement(c.Request.Context(), boardID, elementID, deletedBy)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден в корзине"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка восстановления элемента"})
+		return
+	}
+
+	// Для остальных участников восстановленные элементы выглядят как новые
+	for _, element := range restored {
+		h.hub.Broadcast(boardID, realtime.Event{Type: realtime.EventElementCreated, Payload: element})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"elements": restored})
+}
+
+// PurgeTrashedElement окончательно удаляет элемент из корзины
+func (h *BoardHandler) PurgeTrashedElement(c *gin.Context) {
+	boardID, elementID, ok := trashedElementParams(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Пользователь не авторизован"})
+		return
+	}
+
+	deletedBy, ok := h.trashedElementOwner(c, boardID, userID.(int))
+	if !ok {
+		return
+	}
+
+	err := h.boards.PurgeElement(c.Request.Context(), boardID, elementID, deletedBy)
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Элемент не найден в корзине"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка удаления элемента"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Элемент удален окончательно"})
+}
+
+// trashedElementOwner проверяет право редактировать доску и возвращает, чьи удаленные
+// элементы пользователь может восстанавливать и удалять окончательно: создатель доски -
+// любые (0), остальные - только удаленные ими самими, как в списке корзины.
+// Чужие элементы для них выглядят отсутствующими.
+func (h *BoardHandler) trashedElementOwner(c *gin.Context, boardID, userID int) (int, bool) {
+	access, ok := h.requireEditAccess(c, boardID, userID)
+	if !ok {
+		return 0, false
+	}
+	if access.IsCreator {
+		return 0, true
+	}
+	return userID, true
+}
+
+func trashedElementParams(c *gin.Context) (int, int, bool) {
+	boardID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID доски"})
+		return 0, 0, false
+	}
+	elementID, err := strconv.Atoi(c.Param("element_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный ID элемента"})
+		return 0, 0, false
+	}
+	return boardID, elementID, true
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"micromiro/models"
+	"micromiro/render"
+	"micromiro/store"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Выгрузка, которая дольше этого времени числится в работе, считается брошенной
+// остановленным сервером и отрисовывается заново
+const staleExportAfter = 10 * time.Minute
+
+// Сколько раз выгрузку берут в работу, прежде чем признать неудачной
+const maxExportAttempts = 3
+
+// ExportPurgeInterval - как часто удалять выгрузки с истекшим сроком хранения
+const ExportPurgeInterval = time.Hour
+
+// RenderExports возвращает задачу, которая отрисовывает выгрузки из очереди, пока она не опустеет
+func RenderExports(boards store.BoardStore, renderer *render.Renderer, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for {
+			job, err := boards.ClaimExportJob(ctx, time.Now().Add(-staleExportAfter), maxExportAttempts)
+			if errors.Is(err, store.ErrNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := renderExport(ctx, boards, renderer, job, logger); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func renderExport(ctx context.Context, boards store.BoardStore, renderer *render.Renderer, job *models.ExportJob, logger *log.Logger) error {
+	entry := logger.WithFields(log.Fields{"job": "exports", "export_id": job.ID, "board_id": job.BoardID})
+
+	board, err := boards.GetBoard(ctx, job.BoardID)
+	if errors.Is(err, store.ErrNotFound) {
+		return boards.FinishExportJob(ctx, job.ID, 0, nil, "Доска не найдена")
+	} else if err != nil {
+		return err
+	}
+	elements, err := boards.ListElements(ctx, job.BoardID)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	var out bytes.Buffer
+	if err := renderer.Export(&out, job.Format, elements, job.Params); err != nil {
+		entry.Warnf("Не удалось отрисовать выгрузку: %v", err)
+		return boards.FinishExportJob(ctx, job.ID, board.Version, nil, ExportFailure(err))
+	}
+	entry.Infof("Выгрузка %s готова за %s, %d байт", job.Format, time.Since(started).Round(time.Millisecond), out.Len())
+	return boards.FinishExportJob(ctx, job.ID, board.Version, out.Bytes(), "")
+}
+
+// ExportFailure возвращает понятную пользователю причину, по которой не удалось отрисовать доску
+func ExportFailure(err error) string {
+	switch {
+	case errors.Is(err, render.ErrFrameNotFound):
+		return "Рамка не найдена на доске"
+	case errors.Is(err, render.ErrImageTooLarge):
+		return "Картинка слишком большая, уменьшите масштаб или область"
+	default:
+		return "Ошибка отрисовки доски"
+	}
+}
+
+// PurgeExports возвращает задачу, которая удаляет выгрузки, завершившиеся раньше чем retention назад
+func PurgeExports(boards store.BoardStore, retention time.Duration, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := boards.PurgeExportJobs(ctx, time.Now().Add(-retention))
+		if purged > 0 {
+			logger.WithField("job", "exports").Infof("Удалено устаревших выгрузок: %d", purged)
+		}
+		return err
+	}
+}
@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"micromiro/env"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config - настройки фоновых задач
+type Config struct {
+	// RevisionInterval - как часто сохранять ревизии изменившихся досок, 0 отключает задачу
+	RevisionInterval time.Duration
+	// RevisionRetention - сколько хранятся автоматические ревизии. Удаляются они вместе
+	// с очисткой корзины, раз в TrashPurgeInterval.
+	RevisionRetention time.Duration
+	// RevisionKeep - сколько последних автоматических ревизий доски хранится независимо от возраста
+	RevisionKeep int
+	// TrashRetention - сколько удаленные доски и элементы хранятся в корзине
+	TrashRetention time.Duration
+	// TrashPurgeInterval - как часто очищать корзину, 0 отключает задачу
+	TrashPurgeInterval time.Duration
+	// ExportInterval - как часто проверять очередь выгрузок PNG и PDF, 0 отключает задачу
+	ExportInterval time.Duration
+	// ExportRetention - сколько хранятся готовые файлы выгрузок
+	ExportRetention time.Duration
+	// ThumbnailInterval - как часто проверять изменения досок для превью, 0 отключает задачу.
+	// Превью рисуется, когда доска не менялась весь интервал.
+	ThumbnailInterval time.Duration
+}
+
+// LoadConfig читает настройки фоновых задач из переменных окружения
+// REVISION_INTERVAL, REVISION_RETENTION, REVISION_KEEP, TRASH_RETENTION, TRASH_PURGE_INTERVAL,
+// EXPORT_INTERVAL, EXPORT_RETENTION и THUMBNAIL_INTERVAL.
+// Длительности задаются в формате Go, например "10m".
+func LoadConfig() (Config, error) {
+	config := Config{
+		RevisionInterval:   10 * time.Minute,
+		RevisionRetention:  7 * 24 * time.Hour,
+		RevisionKeep:       20,
+		TrashRetention:     30 * 24 * time.Hour,
+		TrashPurgeInterval: time.Hour,
+		ExportInterval:     2 * time.Second,
+		ExportRetention:    24 * time.Hour,
+		ThumbnailInterval:  15 * time.Second,
+	}
+
+	var err error
+	if config.RevisionInterval, err = env.Duration("REVISION_INTERVAL", config.RevisionInterval); err != nil {
+		return config, err
+	}
+	if config.RevisionRetention, err = env.Duration("REVISION_RETENTION", config.RevisionRetention); err != nil {
+		return config, err
+	}
+	if config.RevisionKeep, err = env.Int("REVISION_KEEP", config.RevisionKeep); err != nil {
+		return config, err
+	}
+	if config.TrashRetention, err = env.Duration("TRASH_RETENTION", config.TrashRetention); err != nil {
+		return config, err
+	}
+	if config.TrashPurgeInterval, err = env.Duration("TRASH_PURGE_INTERVAL", config.TrashPurgeInterval); err != nil {
+		return config, err
+	}
+	if config.ExportInterval, err = env.Duration("EXPORT_INTERVAL", config.ExportInterval); err != nil {
+		return config, err
+	}
+	if config.ExportRetention, err = env.Duration("EXPORT_RETENTION", config.ExportRetention); err != nil {
+		return config, err
+	}
+	if config.ThumbnailInterval, err = env.Duration("THUMBNAIL_INTERVAL", config.ThumbnailInterval); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Every вызывает task с заданным интервалом, пока не отменен ctx.
+// Ошибки задачи пишутся в лог и не останавливают следующие запуски.
+func Every(ctx context.Context, interval time.Duration, name string, task func(ctx context.Context) error, logger *log.Logger) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := task(ctx); err != nil {
+				logger.WithField("job", name).Errorf("Ошибка фоновой задачи: %v", err)
+			}
+		}
+	}
+}
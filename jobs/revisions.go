@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"micromiro/models"
+	"micromiro/store"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SnapshotRevisions возвращает задачу, которая сохраняет автоматическую ревизию
+// каждой доски, изменившейся после её последней ревизии
+func SnapshotRevisions(boards store.BoardStore) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := boards.ListBoardsChangedSinceRevision(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			// Доска могла быть удалена после выборки
+			if _, err := boards.CreateRevision(ctx, id, nil, models.RevisionAuto, ""); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// PurgeRevisions возвращает задачу, которая удаляет автоматические ревизии старше retention,
+// оставляя у каждой доски keep последних
+func PurgeRevisions(boards store.BoardStore, retention time.Duration, keep int, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := boards.PurgeAutoRevisions(ctx, time.Now().Add(-retention), keep)
+		if purged > 0 {
+			logger.WithField("job", "revisions-purge").Infof("Удалено автоматических ревизий: %d", purged)
+		}
+		return err
+	}
+}
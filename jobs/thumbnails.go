@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"micromiro/render"
+	"micromiro/store"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RenderThumbnails возвращает задачу, которая рисует превью досок для списка досок.
+// Превью доски перерисовывается, только если она не менялась с предыдущего запуска
+// задачи, поэтому частые изменения вроде перетаскивания элемента не вызывают отрисовку
+// на каждом шаге.
+func RenderThumbnails(boards store.BoardStore, renderer *render.Renderer, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := boards.ListBoardsNeedingThumbnail(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := renderThumbnail(ctx, boards, renderer, id, logger); err != nil {
+				return err
+			}
+		}
+		// Изменения, замеченные сейчас, попадут в превью при следующем запуске, если доска успокоится
+		_, err = boards.NoteThumbnailChanges(ctx)
+		return err
+	}
+}
+
+func renderThumbnail(ctx context.Context, boards store.BoardStore, renderer *render.Renderer, boardID int, logger *log.Logger) error {
+	// Версия читается раньше элементов: если доска изменится между запросами,
+	// превью будет помечено старой версией и перерисуется
+	board, err := boards.GetBoard(ctx, boardID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	elements, err := boards.ListElements(ctx, boardID)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := renderer.Thumbnail(&out, elements); err != nil {
+		// Без превью в списке показывается заглушка, повторять отрисовку той же версии незачем
+		logger.WithFields(log.Fields{"job": "thumbnails", "board_id": boardID}).Warnf("Не удалось нарисовать превью доски: %v", err)
+		return boards.SaveThumbnail(ctx, boardID, board.Version, nil)
+	}
+	return boards.SaveThumbnail(ctx, boardID, board.Version, out.Bytes())
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"micromiro/store"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TokenPurgeInterval - как часто удалять истекшие сессии, токены обновления и записи черного списка
+const TokenPurgeInterval = time.Hour
+
+// PurgeTokens возвращает задачу, которая удаляет истекшие сессии и токены: после истечения
+// они недействительны и без записи в БД
+func PurgeTokens(tokens store.TokenStore, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := tokens.PurgeTokens(ctx, time.Now())
+		if purged > 0 {
+			logger.WithField("job", "tokens").Infof("Удалено истекших токенов: %d", purged)
+		}
+		return err
+	}
+}
@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	"context"
+	"micromiro/store"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PurgeTrash возвращает задачу, которая окончательно удаляет доски и элементы,
+// пролежавшие в корзине дольше retention
+func PurgeTrash(boards store.BoardStore, retention time.Duration, logger *log.Logger) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		purged, err := boards.PurgeTrash(ctx, time.Now().Add(-retention))
+		if purged > 0 {
+			logger.WithField("job", "trash").Infof("Из корзины удалено записей: %d", purged)
+		}
+		return err
+	}
+}
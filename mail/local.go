@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// secretParam находит в тексте письма значения параметров token, по которым можно войти
+// в аккаунт: они не должны попадать в лог
+var secretParam = regexp.MustCompile(`(?i)\btoken=[^\s&]+`)
+
+// LogMailer пишет письма в лог вместо отправки. Токены в ссылках скрываются, поэтому
+// отправитель безопасен как значение по умолчанию; чтобы пройти по ссылке из письма
+// при локальной разработке, нужен отправитель в файлы.
+type LogMailer struct {
+	logger *log.Logger
+	from   string
+}
+
+// NewLogMailer создает отправителя в лог
+func NewLogMailer(logger *log.Logger, from string) *LogMailer {
+	return &LogMailer{logger: logger, from: from}
+}
+
+// Send пишет письмо в лог, скрывая токены
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	body := secretParam.ReplaceAllString(msg.Body, "token=[скрыто]")
+	m.logger.WithFields(log.Fields{"mail_from": m.from, "mail_to": msg.To, "subject": msg.Subject}).Infof("Письмо:\n%s", body)
+	return nil
+}
+
+// FileMailer сохраняет каждое письмо в отдельный файл .eml, который открывается
+// почтовым клиентом
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer создает отправителя в каталог dir, создавая его при необходимости
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send сохраняет письмо в файл
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := encode(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102-150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0644)
+}
@@ -0,0 +1,108 @@
+// Package mail отправляет письма пользователям: через SMTP или, для локальной разработки,
+// в лог и в файлы.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Message - текстовое письмо одному получателю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv создает отправителя писем по переменным окружения. MAIL_DRIVER выбирает способ:
+//   - smtp - сервер SMTP_HOST:SMTP_PORT (по умолчанию порт 587), с входом SMTP_USERNAME
+//     и SMTP_PASSWORD, если они заданы;
+//   - file - каждое письмо сохраняется в каталог MAIL_DIR (по умолчанию mail) файлом .eml;
+//   - log (по умолчанию) - письма пишутся в лог приложения со скрытыми токенами.
+//
+// Адрес отправителя задается MAIL_FROM.
+func NewFromEnv(logger *log.Logger) (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "MicroMiro <no-reply@micromiro.local>"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required for MAIL_DRIVER=smtp")
+		}
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 || n > 65535 {
+				return nil, fmt.Errorf("invalid SMTP_PORT: %q", value)
+			}
+			port = n
+		}
+		return NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileMailer(dir, from)
+	case "", "log":
+		return NewLogMailer(logger, from), nil
+	default:
+		return nil, fmt.Errorf("invalid MAIL_DRIVER: %q", driver)
+	}
+}
+
+// encode собирает письмо в формате RFC 5322. Текст кодируется quoted-printable,
+// чтобы кириллица доходила через серверы без поддержки 8BITMIME.
+func encode(from string, msg Message, now time.Time) ([]byte, error) {
+	if strings.ContainsAny(msg.To+msg.Subject+from, "\r\n") {
+		return nil, fmt.Errorf("mail header contains line break")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "micromiro.local"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	out.WriteString("MIME-Version: 1.0\r\n")
+	out.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	out.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&out)
+	if _, err := body.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
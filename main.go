@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
 	"io"
 	"os"
 
 	"micromiro/database"
 	"micromiro/handlers"
+	"micromiro/jobs"
+	"micromiro/mail"
 	"micromiro/middleware"
+	"micromiro/realtime"
+	"micromiro/render"
+	"micromiro/store"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -41,14 +47,68 @@ func main() {
 	}
 	defer db.Close()
 
+	// micromiro migrate up|down|status - управление схемой без запуска сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			logger.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	applied, err := database.MigrateUp(db)
+	if err != nil {
+		logger.Fatalf("failed to apply migrations: %v", err)
+	}
+	for _, migration := range applied {
+		logger.WithField("version", migration.Version).Infof("Применена миграция %s", migration.Name)
+	}
+
+	// Все обработчики работают через один пул соединений
+	userStore := store.NewUserStore(db)
+	boardStore := store.NewBoardStore(db)
+	tokenStore := store.NewTokenStore(db)
+
+	authConfig, err := handlers.LoadAuthConfig()
+	if err != nil {
+		logger.Fatalf("failed to load auth config: %v", err)
+	}
+	mailer, err := mail.NewFromEnv(logger)
+	if err != nil {
+		logger.Fatalf("failed to configure mail: %v", err)
+	}
+	// Хаб общий: при завершении сессий обработчик аутентификации закрывает их подключения
+	hub := realtime.NewHub()
+	authHandler := handlers.NewAuthHandler(userStore, tokenStore, mailer, hub, authConfig, logger)
+
+	// Текст в PNG и PDF рисуется шрифтом из RENDER_FONT, без него - схематично
+	renderer, err := render.NewRenderer(os.Getenv("RENDER_FONT"))
+	if err != nil {
+		logger.Fatalf("failed to load render font: %v", err)
+	}
+	boardHandler := handlers.NewBoardHandler(boardStore, userStore, hub, renderer)
+
+	// Фоновые задачи работают, пока жив процесс сервера
+	jobsConfig, err := jobs.LoadConfig()
+	if err != nil {
+		logger.Fatalf("invalid jobs configuration: %v", err)
+	}
+	go jobs.Every(context.Background(), jobsConfig.RevisionInterval, "revisions", jobs.SnapshotRevisions(boardStore), logger)
+	go jobs.Every(context.Background(), jobsConfig.TrashPurgeInterval, "trash", jobs.PurgeTrash(boardStore, jobsConfig.TrashRetention, logger), logger)
+	go jobs.Every(context.Background(), jobsConfig.TrashPurgeInterval, "revisions-purge", jobs.PurgeRevisions(boardStore, jobsConfig.RevisionRetention, jobsConfig.RevisionKeep, logger), logger)
+	go jobs.Every(context.Background(), jobsConfig.ExportInterval, "exports", jobs.RenderExports(boardStore, renderer, logger), logger)
+	go jobs.Every(context.Background(), jobs.ExportPurgeInterval, "exports-purge", jobs.PurgeExports(boardStore, jobsConfig.ExportRetention, logger), logger)
+	go jobs.Every(context.Background(), jobsConfig.ThumbnailInterval, "thumbnails", jobs.RenderThumbnails(boardStore, renderer, logger), logger)
+	go jobs.Every(context.Background(), jobs.TokenPurgeInterval, "tokens", jobs.PurgeTokens(tokenStore, logger), logger)
+
 	router := gin.Default()
 
 	// Настройка CORS
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -65,12 +125,23 @@ func main() {
 				"message": "pong",
 			})
 		})	
-		v1.POST("/register", handlers.Register)
-		v1.POST("/login", handlers.Login)
+		v1.POST("/register", authHandler.Register)
+		v1.POST("/login", authHandler.Login)
+		v1.POST("/refresh", authHandler.Refresh)
+		v1.POST("/password/forgot", authHandler.ForgotPassword)
+		v1.POST("/password/reset", authHandler.ResetPassword)
+
+		// Публичные доски доступны без авторизации и только для чтения
+		v1.GET("/public/boards/:slug", boardHandler.GetPublicBoard)
 
 		protected := v1.Group("/protected")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(tokenStore))
 		{
+			protected.POST("/logout", authHandler.Logout)
+			protected.POST("/logout/all", authHandler.LogoutAll)
+			protected.GET("/sessions", authHandler.ListSessions)
+			protected.DELETE("/sessions/:session_id", authHandler.RevokeSession)
+
 			protected.GET("/profile", func(c *gin.Context){
 				userID, _ := c.Get("user_id")
 				email, _ := c.Get("email")
@@ -83,17 +154,75 @@ func main() {
 			// Эндпоинты для работы с досками
 			boards := protected.Group("/boards")
 			{
-				boards.POST("", handlers.CreateBoard)
-				boards.GET("", handlers.GetBoards)
-				boards.GET("/:id", handlers.GetBoard)
-				boards.PUT("/:id", handlers.UpdateBoard)
-				boards.DELETE("/:id", handlers.DeleteBoard)
+				boards.POST("", boardHandler.CreateBoard)
+				boards.GET("", boardHandler.GetBoards)
+				boards.GET("/:id", boardHandler.GetBoard)
+				boards.PUT("/:id", boardHandler.UpdateBoard)
+				boards.DELETE("/:id", boardHandler.DeleteBoard)
+
+				// Перенос доски между серверами и резервные копии в JSON
+				boards.GET("/:id/export", boardHandler.ExportBoard)
+				boards.POST("/import", boardHandler.ImportBoard)
+
+				// Картинки доски: SVG для вставки в вики и описания PR, PNG и PDF для выгрузки.
+				// Большие доски выгружаются фоновой задачей.
+				boards.GET("/:id/render.svg", boardHandler.RenderBoardSVG)
+				boards.GET("/:id/export.png", boardHandler.ExportBoardPNG)
+				boards.GET("/:id/export.pdf", boardHandler.ExportBoardPDF)
+				boards.GET("/:id/thumbnail.png", boardHandler.GetBoardThumbnail)
+				boards.GET("/:id/exports/:export_id", boardHandler.GetExportJob)
+				boards.GET("/:id/exports/:export_id/file", boardHandler.DownloadExportJob)
 
 				// Эндпоинты для работы с элементами досок
-				boards.POST("/:id/elements", handlers.CreateBoardElement)
-				boards.PUT("/:id/elements/:element_id", handlers.UpdateBoardElement)
-				boards.DELETE("/:id/elements/:element_id", handlers.DeleteBoardElement)
+				boards.POST("/:id/elements", boardHandler.CreateBoardElement)
+				boards.PUT("/:id/elements/:element_id", boardHandler.UpdateBoardElement)
+				boards.PATCH("/:id/elements/:element_id", boardHandler.PatchBoardElement)
+				boards.DELETE("/:id/elements/:element_id", boardHandler.DeleteBoardElement)
+				boards.POST("/:id/elements/:element_id/order", boardHandler.ReorderBoardElement)
+				boards.POST("/:id/elements/batch", boardHandler.ApplyElementBatch)
+
+				// Отмена и повтор собственных операций пользователя над элементами доски
+				boards.POST("/:id/undo", boardHandler.UndoBoardOperation)
+				boards.POST("/:id/redo", boardHandler.RedoBoardOperation)
+
+				// Эндпоинты для управления доступом к доске
+				boards.GET("/:id/permissions", boardHandler.GetBoardPermissions)
+				boards.POST("/:id/permissions", boardHandler.GrantBoardPermission)
+				boards.PUT("/:id/permissions/:user_id", boardHandler.UpdateBoardPermission)
+				boards.DELETE("/:id/permissions/:user_id", boardHandler.RevokeBoardPermission)
+
+				// Эндпоинты для ссылок-приглашений на доску
+				boards.GET("/:id/invites", boardHandler.GetBoardInvites)
+				boards.POST("/:id/invites", boardHandler.CreateBoardInvite)
+				boards.DELETE("/:id/invites/:invite_id", boardHandler.RevokeBoardInvite)
+
+				// Эндпоинты для публичной ссылки на доску
+				boards.GET("/:id/public-link", boardHandler.GetBoardPublicLink)
+				boards.POST("/:id/public-link/rotate", boardHandler.RotateBoardPublicLink)
+
+				// Поток изменений доски в реальном времени и догоняющая синхронизация после переподключения
+				boards.GET("/:id/ws", boardHandler.BoardWebSocket)
+				boards.GET("/:id/presence", boardHandler.GetBoardPresence)
+				boards.GET("/:id/changes", boardHandler.GetBoardChanges)
+
+				// Эндпоинты для истории ревизий доски
+				boards.GET("/:id/revisions", boardHandler.GetBoardRevisions)
+				boards.POST("/:id/revisions", boardHandler.CreateBoardRevision)
+				boards.GET("/:id/revisions/:revision_id", boardHandler.GetBoardRevision)
+				boards.POST("/:id/revisions/:revision_id/restore", boardHandler.RestoreBoardRevision)
+
+				// Удаленные элементы доски в корзине
+				boards.POST("/:id/trash/elements/:element_id/restore", boardHandler.RestoreTrashedElement)
+				boards.DELETE("/:id/trash/elements/:element_id", boardHandler.PurgeTrashedElement)
 			}
+
+			// Корзина пользователя: удаленные доски и элементы
+			protected.GET("/trash", boardHandler.GetTrash)
+			protected.POST("/trash/boards/:id/restore", boardHandler.RestoreTrashedBoard)
+			protected.DELETE("/trash/boards/:id", boardHandler.PurgeTrashedBoard)
+
+			// Принятие приглашения доступно любому авторизованному пользователю
+			protected.POST("/invites/:token/accept", boardHandler.AcceptBoardInvite)
 		}
 	}
 
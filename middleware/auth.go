@@ -1,17 +1,31 @@
 package middleware
 
 import (
+    "micromiro/store"
     "net/http"
     "os"
     "strings"
+    "sync"
+    "time"
 
     "github.com/gin-gonic/gin"
     "github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// Как часто записывается время последнего использования сессии
+const sessionTouchInterval = time.Minute
+
+// AuthMiddleware проверяет токен доступа и отклоняет токены из черного списка tokens
+// и токены завершенных сессий
+func AuthMiddleware(tokens store.TokenStore) gin.HandlerFunc {
+    touches := &touchThrottle{interval: sessionTouchInterval, last: map[string]time.Time{}}
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
+        // Браузер не умеет передавать заголовки при открытии WebSocket,
+        // поэтому для него токен принимается из параметра запроса
+        if authHeader == "" && isWebSocketUpgrade(c) && c.Query("token") != "" {
+            authHeader = "Bearer " + c.Query("token")
+        }
         if authHeader == "" {
             c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
             c.Abort()
@@ -37,12 +51,73 @@ func AuthMiddleware() gin.HandlerFunc {
             return
         }
 
-        if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-            c.Set("user_id", int(claims["user_id"].(float64)))
-            c.Set("email", claims["email"].(string))
-            c.Set("role_id", int(claims["role_id"].(float64)))
+        claims, _ := token.Claims.(jwt.MapClaims)
+        // Токены без jti и sid выданы до появления сессий, их нельзя отозвать
+        jti, _ := claims["jti"].(string)
+        sessionID, _ := claims["sid"].(string)
+        if jti == "" || sessionID == "" {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+            c.Abort()
+            return
+        }
+
+        revoked, err := tokens.IsTokenRevoked(c.Request.Context(), jti, sessionID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+            c.Abort()
+            return
+        }
+        if revoked {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+            c.Abort()
+            return
+        }
+
+        // Время использования сессии записывается не чаще раза в sessionTouchInterval
+        if now := time.Now(); touches.due(sessionID, now) {
+            if err := tokens.TouchSession(c.Request.Context(), sessionID, now); err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+                c.Abort()
+                return
+            }
         }
 
+        c.Set("user_id", int(claims["user_id"].(float64)))
+        c.Set("email", claims["email"].(string))
+        c.Set("role_id", int(claims["role_id"].(float64)))
+        c.Set("session_id", sessionID)
+
         c.Next()
     }
-}
\ No newline at end of file
+}
+
+func isWebSocketUpgrade(c *gin.Context) bool {
+    return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
+
+// touchThrottle ограничивает частоту записи времени использования сессий
+type touchThrottle struct {
+    interval time.Duration
+    mu       sync.Mutex
+    last     map[string]time.Time
+}
+
+// due сообщает, пора ли записать использование сессии, и если пора, запоминает время записи
+func (t *touchThrottle) due(sessionID string, now time.Time) bool {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if last, ok := t.last[sessionID]; ok && now.Sub(last) < t.interval {
+        return false
+    }
+    t.last[sessionID] = now
+    // Давно не использованные сессии забываются, чтобы карта не росла бесконечно
+    if len(t.last) > 10000 {
+        for id, last := range t.last {
+            if now.Sub(last) >= t.interval {
+                delete(t.last, id)
+            }
+        }
+    }
+    return true
+}
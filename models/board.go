@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Board struct {
 	ID          int       `json:"id"`
@@ -8,21 +11,33 @@ type Board struct {
 	Description string    `json:"description"`
 	CreatorID   int       `json:"creator_id"`
 	IsPublic    bool      `json:"is_public"`
+	Version     int64     `json:"version"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// DeletedAt задан у досок в корзине
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// ThumbnailURL - адрес картинки-превью доски для списка досок
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
 }
 
 type BoardElement struct {
-	ID        int       `json:"id"`
-	BoardID   int       `json:"board_id"`
-	Type      string    `json:"type"`
-	Content   string    `json:"content"`
-	PositionX int       `json:"position_x"`
-	PositionY int       `json:"position_y"`
-	Width     int       `json:"width"`
-	Height    int       `json:"height"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int          `json:"id"`
+	BoardID   int          `json:"board_id"`
+	Type      string       `json:"type"`
+	Content   string       `json:"content"`
+	PositionX int          `json:"position_x"`
+	PositionY int          `json:"position_y"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Style     ElementStyle `json:"style"`
+	ZIndex    int          `json:"z_index"`
+	Rotation  float64      `json:"rotation"`
+	Locked    bool         `json:"locked"`
+	Connector *Connector   `json:"connector,omitempty"`
+	ParentID  *int         `json:"parent_id"`
+	Version   int64        `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
 }
 
 type CreateBoardRequest struct {
@@ -38,19 +53,131 @@ type UpdateBoardRequest struct {
 }
 
 type CreateBoardElementRequest struct {
-	Type      string `json:"type" binding:"required"`
-	Content   string `json:"content"`
-	PositionX int    `json:"position_x"`
-	PositionY int    `json:"position_y"`
-	Width     int    `json:"width"`
-	Height    int    `json:"height"`
+	Type      string       `json:"type" binding:"required"`
+	Content   string       `json:"content"`
+	PositionX int          `json:"position_x"`
+	PositionY int          `json:"position_y"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Style     ElementStyle `json:"style"`
+	Rotation  float64      `json:"rotation" binding:"gte=-360,lte=360"`
+	Locked    bool         `json:"locked"`
+	Connector *Connector   `json:"connector"`
+	ParentID  *int         `json:"parent_id"`
 }
 
+// UpdateBoardElementRequest - новое состояние элемента. Без locked блокировка не меняется.
 type UpdateBoardElementRequest struct {
-	Type      string `json:"type"`
-	Content   string `json:"content"`
-	PositionX int    `json:"position_x"`
-	PositionY int    `json:"position_y"`
-	Width     int    `json:"width"`
-	Height    int    `json:"height"`
+	Type      string       `json:"type"`
+	Content   string       `json:"content"`
+	PositionX int          `json:"position_x"`
+	PositionY int          `json:"position_y"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Style     ElementStyle `json:"style"`
+	Rotation  float64      `json:"rotation" binding:"gte=-360,lte=360"`
+	Locked    *bool        `json:"locked"`
+	Connector *Connector   `json:"connector"`
+	ParentID  *int         `json:"parent_id"`
+}
+
+type BoardPermission struct {
+	ID        int       `json:"id"`
+	BoardID   int       `json:"board_id"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	CanEdit   bool      `json:"can_edit"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GrantBoardPermissionRequest - пользователь указывается по email или по имени
+type GrantBoardPermissionRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	CanEdit  bool   `json:"can_edit"`
+}
+
+type UpdateBoardPermissionRequest struct {
+	CanEdit *bool `json:"can_edit" binding:"required"`
+}
+
+// Роли, которые выдает ссылка-приглашение
+const (
+	InviteRoleViewer = "viewer"
+	InviteRoleEditor = "editor"
+)
+
+type BoardInvite struct {
+	ID        int        `json:"id"`
+	BoardID   int        `json:"board_id"`
+	Token     string     `json:"token"`
+	Role      string     `json:"role"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxUses   *int       `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreateBoardInviteRequest struct {
+	Role      string     `json:"role" binding:"required,oneof=viewer editor"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxUses   *int       `json:"max_uses" binding:"omitempty,min=1"`
+}
+
+// PublicLink описывает ссылку для анонимного просмотра доски
+type PublicLink struct {
+	BoardID  int     `json:"board_id"`
+	Slug     *string `json:"slug"`
+	IsPublic bool    `json:"is_public"`
+}
+
+// Действия для изменения порядка наложения элемента
+const (
+	ZOrderForward  = "forward"
+	ZOrderBackward = "backward"
+	ZOrderFront    = "front"
+	ZOrderBack     = "back"
+)
+
+type ReorderElementRequest struct {
+	Action string `json:"action" binding:"required,oneof=forward backward front back"`
+}
+
+// ElementOrder - новый z-index элемента после изменения порядка
+type ElementOrder struct {
+	ID      int   `json:"id"`
+	ZIndex  int   `json:"z_index"`
+	Version int64 `json:"version"`
+}
+
+// ElementBatchRequest - пакет операций над элементами доски
+type ElementBatchRequest struct {
+	Operations []ElementOperation `json:"operations" binding:"required,min=1,max=500,dive"`
+}
+
+// ElementOperation - операция пакета. Для create нужны element и, по желанию, temp_id,
+// для update - element_id и element как документ JSON Merge Patch, для delete - element_id.
+// if_match, как заголовок If-Match, применяет update и delete только к элементу этой версии.
+type ElementOperation struct {
+	Op         string          `json:"op" binding:"required,oneof=create update delete"`
+	TempID     string          `json:"temp_id"`
+	ElementID  int             `json:"element_id"`
+	Element    json.RawMessage `json:"element"`
+	IfMatch    int64           `json:"if_match"`
+	Connectors string          `json:"connectors" binding:"omitempty,oneof=delete detach"`
+	Children   string          `json:"children" binding:"omitempty,oneof=orphan cascade"`
+}
+
+// ElementOperationResult - результат операции пакета: ok, error или skipped,
+// если пакет не применен из-за ошибки в другой операции
+type ElementOperationResult struct {
+	Index     int    `json:"index"`
+	Op        string `json:"op"`
+	TempID    string `json:"temp_id,omitempty"`
+	ElementID int    `json:"element_id,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
 }
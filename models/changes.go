@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ElementTombstone - отметка об удаленном элементе в ленте изменений
+type ElementTombstone struct {
+	ID        int       `json:"id"`
+	Version   int64     `json:"version"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// BoardChanges - изменения доски после курсора. Cursor - версия доски,
+// с которой нужно запрашивать следующую порцию изменений.
+type BoardChanges struct {
+	Cursor   int64              `json:"cursor"`
+	Board    *Board             `json:"board"`
+	Elements []BoardElement     `json:"elements"`
+	Deleted  []ElementTombstone `json:"deleted"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// ElementTypeConnector - тип элемента для стрелок и линий между элементами
+const ElementTypeConnector = "connector"
+
+// Стороны элемента, к которым крепится конец коннектора
+const (
+	AnchorAuto   = "auto"
+	AnchorTop    = "top"
+	AnchorRight  = "right"
+	AnchorBottom = "bottom"
+	AnchorLeft   = "left"
+	AnchorCenter = "center"
+)
+
+// Point - точка в координатах холста
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ConnectorEnd - конец коннектора. Если ElementID задан, конец прикреплен к элементу
+// и следует за ним, иначе он свободно лежит в точке X, Y.
+type ConnectorEnd struct {
+	ElementID *int    `json:"element_id"`
+	Anchor    string  `json:"anchor,omitempty" binding:"omitempty,oneof=auto top right bottom left center"`
+	X         float64 `json:"x"`
+	Y         float64 `json:"y"`
+	Arrowhead string  `json:"arrowhead,omitempty" binding:"omitempty,oneof=none arrow triangle circle diamond"`
+}
+
+// Connector - данные элемента типа connector
+type Connector struct {
+	Source    ConnectorEnd `json:"source"`
+	Target    ConnectorEnd `json:"target"`
+	Waypoints []Point      `json:"waypoints" binding:"max=100"`
+	Label     string       `json:"label,omitempty" binding:"max=500"`
+}
+
+// Value сохраняет коннектор в колонку jsonb
+func (c Connector) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Translate сдвигает свободные концы и промежуточные точки коннектора
+func (c *Connector) Translate(dx, dy float64) {
+	for _, end := range []*ConnectorEnd{&c.Source, &c.Target} {
+		if end.ElementID == nil {
+			end.X += dx
+			end.Y += dy
+		}
+	}
+	for i := range c.Waypoints {
+		c.Waypoints[i].X += dx
+		c.Waypoints[i].Y += dy
+	}
+}
+
+// ValidateConnector проверяет, что данные коннектора переданы только для элемента типа connector
+func ValidateConnector(elementType string, connector *Connector) error {
+	if elementType != ElementTypeConnector {
+		if connector != nil {
+			return errors.New("Поле connector допустимо только для элементов типа connector")
+		}
+		return nil
+	}
+	if connector == nil {
+		return errors.New("Для элемента типа connector нужно поле connector")
+	}
+	return nil
+}
+
+// AnchorPoint возвращает точку на границе элемента, к которой крепится коннектор
+func (e BoardElement) AnchorPoint(anchor string) Point {
+	x, y := float64(e.PositionX), float64(e.PositionY)
+	w, h := float64(e.Width), float64(e.Height)
+
+	switch anchor {
+	case AnchorTop:
+		return Point{X: x + w/2, Y: y}
+	case AnchorRight:
+		return Point{X: x + w, Y: y + h/2}
+	case AnchorBottom:
+		return Point{X: x + w/2, Y: y + h}
+	case AnchorLeft:
+		return Point{X: x, Y: y + h/2}
+	default:
+		return Point{X: x + w/2, Y: y + h/2}
+	}
+}
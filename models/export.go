@@ -0,0 +1,149 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// BoardExportFormat - идентификатор формата документа экспорта доски
+const BoardExportFormat = "micromiro.board"
+
+// BoardExportVersion - текущая версия формата. Импорт принимает документы этой и более ранних версий.
+const BoardExportVersion = 1
+
+// BoardExport - самодостаточный документ с доской и её элементами. ID элементов
+// в документе действуют только внутри него, при импорте элементы получают новые ID.
+type BoardExport struct {
+	Format     string            `json:"format" binding:"required,eq=micromiro.board"`
+	Version    int               `json:"version" binding:"required,min=1"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Board      ExportedBoard     `json:"board"`
+	Elements   []ExportedElement `json:"elements" binding:"max=10000,dive"`
+}
+
+// ExportedBoard - свойства доски в документе экспорта. Доступ и публичная ссылка не переносятся.
+type ExportedBoard struct {
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExportedElement - элемент в документе экспорта. parent_id и концы коннекторов
+// ссылаются на ID других элементов того же документа.
+type ExportedElement struct {
+	ID        int          `json:"id" binding:"required,min=1"`
+	Type      string       `json:"type" binding:"required"`
+	Content   string       `json:"content"`
+	PositionX int          `json:"position_x"`
+	PositionY int          `json:"position_y"`
+	Width     int          `json:"width" binding:"gte=0"`
+	Height    int          `json:"height" binding:"gte=0"`
+	Style     ElementStyle `json:"style"`
+	ZIndex    int          `json:"z_index"`
+	Rotation  float64      `json:"rotation" binding:"gte=-360,lte=360"`
+	Locked    bool         `json:"locked"`
+	Connector *Connector   `json:"connector,omitempty"`
+	ParentID  *int         `json:"parent_id"`
+}
+
+// NewBoardExport собирает документ экспорта из доски и её элементов
+func NewBoardExport(board *Board, elements []BoardElement) BoardExport {
+	export := BoardExport{
+		Format:     BoardExportFormat,
+		Version:    BoardExportVersion,
+		ExportedAt: time.Now(),
+		Board:      ExportedBoard{Title: board.Title, Description: board.Description, CreatedAt: board.CreatedAt},
+		Elements:   make([]ExportedElement, 0, len(elements)),
+	}
+	for _, e := range elements {
+		export.Elements = append(export.Elements, ExportedElement{
+			ID:        e.ID,
+			Type:      e.Type,
+			Content:   e.Content,
+			PositionX: e.PositionX,
+			PositionY: e.PositionY,
+			Width:     e.Width,
+			Height:    e.Height,
+			Style:     e.Style,
+			ZIndex:    e.ZIndex,
+			Rotation:  e.Rotation,
+			Locked:    e.Locked,
+			Connector: e.Connector,
+			ParentID:  e.ParentID,
+		})
+	}
+	return export
+}
+
+// BoardElements возвращает элементы документа с ID и ссылками из документа
+func (e BoardExport) BoardElements() []BoardElement {
+	elements := make([]BoardElement, 0, len(e.Elements))
+	for _, el := range e.Elements {
+		elements = append(elements, BoardElement{
+			ID:        el.ID,
+			Type:      el.Type,
+			Content:   el.Content,
+			PositionX: el.PositionX,
+			PositionY: el.PositionY,
+			Width:     el.Width,
+			Height:    el.Height,
+			Style:     el.Style,
+			ZIndex:    el.ZIndex,
+			Rotation:  el.Rotation,
+			Locked:    el.Locked,
+			Connector: el.Connector,
+			ParentID:  el.ParentID,
+		})
+	}
+	return elements
+}
+
+// Validate проверяет версию документа и ссылки между его элементами: ID уникальны,
+// родитель - рамка или группа без циклов вложенности, коннекторы прикреплены
+// к существующим элементам, которые сами не коннекторы
+func (e BoardExport) Validate() error {
+	if e.Version > BoardExportVersion {
+		return fmt.Errorf("Версия формата %d не поддерживается, последняя поддерживаемая - %d", e.Version, BoardExportVersion)
+	}
+
+	types := make(map[int]string, len(e.Elements))
+	parents := make(map[int]*int, len(e.Elements))
+	for _, el := range e.Elements {
+		if _, exists := types[el.ID]; exists {
+			return fmt.Errorf("Элемент %d встречается в документе несколько раз", el.ID)
+		}
+		types[el.ID] = el.Type
+		parents[el.ID] = el.ParentID
+	}
+
+	for _, el := range e.Elements {
+		if err := ValidateConnector(el.Type, el.Connector); err != nil {
+			return fmt.Errorf("Элемент %d: %v", el.ID, err)
+		}
+		if el.Connector != nil {
+			for _, end := range []ConnectorEnd{el.Connector.Source, el.Connector.Target} {
+				if end.ElementID == nil {
+					continue
+				}
+				if endType, exists := types[*end.ElementID]; !exists || endType == ElementTypeConnector {
+					return fmt.Errorf("Элемент %d: коннектор ссылается на отсутствующий элемент или на другой коннектор", el.ID)
+				}
+			}
+		}
+		if el.ParentID == nil {
+			continue
+		}
+		if parentType, exists := types[*el.ParentID]; !exists || !IsContainer(parentType) {
+			return fmt.Errorf("Элемент %d: родителем может быть только рамка или группа из документа", el.ID)
+		}
+		// Цепочка родителей длиннее числа элементов означает цикл
+		steps := 0
+		for parent := el.ParentID; parent != nil; parent = parents[*parent] {
+			if *parent == el.ID || steps > len(e.Elements) {
+				return fmt.Errorf("Элемент %d: вложенность образует цикл", el.ID)
+			}
+			steps++
+		}
+	}
+	return nil
+}
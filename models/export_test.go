@@ -0,0 +1,145 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoardExportValidate(t *testing.T) {
+	ref := func(id int) *int { return &id }
+	connector := func(source, target *int) *Connector {
+		return &Connector{Source: ConnectorEnd{ElementID: source}, Target: ConnectorEnd{ElementID: target}}
+	}
+
+	tests := []struct {
+		name     string
+		version  int
+		elements []ExportedElement
+		wantErr  string
+	}{
+		{
+			name:    "пустая доска",
+			version: BoardExportVersion,
+		},
+		{
+			name:    "рамка, вложенная группа и коннектор",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: ElementTypeFrame},
+				{ID: 2, Type: ElementTypeGroup, ParentID: ref(1)},
+				{ID: 3, Type: "rectangle", ParentID: ref(2)},
+				{ID: 4, Type: "rectangle"},
+				{ID: 5, Type: ElementTypeConnector, Connector: connector(ref(3), ref(4))},
+				{ID: 6, Type: ElementTypeConnector, Connector: connector(nil, nil)},
+			},
+		},
+		{
+			name:    "родитель объявлен позже ребенка",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 2, Type: "rectangle", ParentID: ref(1)},
+				{ID: 1, Type: ElementTypeFrame},
+			},
+		},
+		{
+			name:    "версия новее поддерживаемой",
+			version: BoardExportVersion + 1,
+			wantErr: "не поддерживается",
+		},
+		{
+			name:    "повторяющийся ID",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: "rectangle"},
+				{ID: 1, Type: "circle"},
+			},
+			wantErr: "несколько раз",
+		},
+		{
+			name:    "родитель отсутствует",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: "rectangle", ParentID: ref(2)},
+			},
+			wantErr: "родителем может быть",
+		},
+		{
+			name:    "родитель не контейнер",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: "rectangle"},
+				{ID: 2, Type: "rectangle", ParentID: ref(1)},
+			},
+			wantErr: "родителем может быть",
+		},
+		{
+			name:    "элемент вложен сам в себя",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: ElementTypeFrame, ParentID: ref(1)},
+			},
+			wantErr: "цикл",
+		},
+		{
+			name:    "цикл из трех контейнеров",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: ElementTypeFrame, ParentID: ref(3)},
+				{ID: 2, Type: ElementTypeGroup, ParentID: ref(1)},
+				{ID: 3, Type: ElementTypeGroup, ParentID: ref(2)},
+			},
+			wantErr: "цикл",
+		},
+		{
+			name:    "цикл выше по цепочке",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 3, Type: "rectangle", ParentID: ref(1)},
+				{ID: 1, Type: ElementTypeFrame, ParentID: ref(2)},
+				{ID: 2, Type: ElementTypeGroup, ParentID: ref(1)},
+			},
+			wantErr: "цикл",
+		},
+		{
+			name:    "коннектор к отсутствующему элементу",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: "rectangle"},
+				{ID: 2, Type: ElementTypeConnector, Connector: connector(ref(1), ref(9))},
+			},
+			wantErr: "коннектор ссылается",
+		},
+		{
+			name:    "коннектор к коннектору",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: ElementTypeConnector, Connector: connector(nil, nil)},
+				{ID: 2, Type: ElementTypeConnector, Connector: connector(ref(1), nil)},
+			},
+			wantErr: "коннектор ссылается",
+		},
+		{
+			name:    "коннектор без данных",
+			version: BoardExportVersion,
+			elements: []ExportedElement{
+				{ID: 1, Type: ElementTypeConnector},
+			},
+			wantErr: "нужно поле connector",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			export := BoardExport{Format: BoardExportFormat, Version: tt.version, Elements: tt.elements}
+			err := export.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, ожидалось nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, ожидалась ошибка с %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+// Типы элементов-контейнеров. Рамка - подписанная область доски, заголовок хранится в content.
+// Группа объединяет элементы, чтобы двигать их вместе.
+const (
+	ElementTypeFrame = "frame"
+	ElementTypeGroup = "group"
+)
+
+// IsContainer сообщает, могут ли у элемента этого типа быть вложенные элементы
+func IsContainer(elementType string) bool {
+	return elementType == ElementTypeFrame || elementType == ElementTypeGroup
+}
+
+// ElementNode - узел дерева вложенности элементов доски
+type ElementNode struct {
+	ID       int           `json:"id"`
+	Children []ElementNode `json:"children"`
+}
+
+// BuildHierarchy строит дерево вложенности по parent_id. Порядок узлов
+// на каждом уровне совпадает с порядком элементов во входном списке.
+func BuildHierarchy(elements []BoardElement) []ElementNode {
+	children := make(map[int][]int)
+	exists := make(map[int]bool, len(elements))
+	for _, element := range elements {
+		exists[element.ID] = true
+	}
+
+	roots := []int{}
+	for _, element := range elements {
+		if element.ParentID != nil && exists[*element.ParentID] {
+			children[*element.ParentID] = append(children[*element.ParentID], element.ID)
+		} else {
+			roots = append(roots, element.ID)
+		}
+	}
+
+	var build func(ids []int) []ElementNode
+	build = func(ids []int) []ElementNode {
+		nodes := make([]ElementNode, 0, len(ids))
+		for _, id := range ids {
+			nodes = append(nodes, ElementNode{ID: id, Children: build(children[id])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
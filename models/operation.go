@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Виды операций в журнале отмены
+const (
+	OperationCreate  = "create"
+	OperationUpdate  = "update"
+	OperationDelete  = "delete"
+	OperationReorder = "reorder"
+	OperationBatch   = "batch"
+)
+
+// ElementChange - состояние элемента до и после операции. Before пуст у созданного
+// операцией элемента, After - у удаленного.
+type ElementChange struct {
+	ID     int           `json:"id"`
+	Before *BoardElement `json:"before"`
+	After  *BoardElement `json:"after"`
+}
+
+// BoardOperation - операция пользователя над элементами доски из журнала отмены
+type BoardOperation struct {
+	ID        int        `json:"id"`
+	BoardID   int        `json:"board_id"`
+	UserID    int        `json:"user_id"`
+	Kind      string     `json:"kind"`
+	CreatedAt time.Time  `json:"created_at"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+}
+
+// OperationReplay - результат отмены или повтора операции
+type OperationReplay struct {
+	Operation BoardOperation `json:"operation"`
+	Version   int64          `json:"version"`
+	Created   []BoardElement `json:"created"`
+	Updated   []BoardElement `json:"updated"`
+	Deleted   []int          `json:"deleted"`
+	// Skipped - сколько более поздних операций пропущено, потому что их элементы с тех пор изменили другие
+	Skipped int `json:"skipped"`
+}
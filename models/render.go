@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Форматы картинок доски, которые можно выгрузить фоновой задачей
+const (
+	ExportFormatPNG = "png"
+	ExportFormatPDF = "pdf"
+)
+
+// Состояния задачи выгрузки
+const (
+	ExportJobPending = "pending"
+	ExportJobRunning = "running"
+	ExportJobDone    = "done"
+	ExportJobFailed  = "failed"
+)
+
+// RenderRegion - прямоугольная область холста
+type RenderRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// RenderParams - что и как рисовать в картинке доски. Если заданы и FrameID, и Region,
+// используется рамка.
+type RenderParams struct {
+	// FrameID - рисовать только рамку и вложенные в неё элементы, в границах рамки
+	FrameID int `json:"frame_id,omitempty"`
+	// Region - рисовать только элементы, пересекающие область, в границах области
+	Region *RenderRegion `json:"region,omitempty"`
+	// Background - цвет фона в формате #rgb или #rrggbb, пустой - прозрачный (в PDF - белый)
+	Background string `json:"background,omitempty"`
+	// Scale - пикселей (в PDF - пунктов) на единицу холста, 0 - масштаб 1
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// ExportJob - фоновая выгрузка большой доски в PNG или PDF
+type ExportJob struct {
+	ID      int          `json:"id"`
+	BoardID int          `json:"board_id"`
+	UserID  int          `json:"user_id"`
+	Format  string       `json:"format"`
+	Params  RenderParams `json:"params"`
+	Status  string       `json:"status"`
+	// Error - причина неудачи для status = failed
+	Error string `json:"error,omitempty"`
+	// BoardVersion - версия доски, с которой сделана картинка
+	BoardVersion *int64 `json:"board_version,omitempty"`
+	// Size - размер готового файла в байтах
+	Size int `json:"size,omitempty"`
+	// DownloadURL - адрес готового файла, заполняется для status = done
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Value сохраняет параметры картинки в колонку jsonb
+func (p RenderParams) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan читает параметры картинки из колонки jsonb
+func (p *RenderParams) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case []byte:
+		return json.Unmarshal(data, p)
+	case string:
+		return json.Unmarshal([]byte(data), p)
+	default:
+		return fmt.Errorf("unsupported render params type %T", src)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Виды ревизий доски
+const (
+	// RevisionManual - сохранена пользователем
+	RevisionManual = "manual"
+	// RevisionAuto - сохранена периодически, если доска изменилась
+	RevisionAuto = "auto"
+	// RevisionRestore - состояние доски перед восстановлением другой ревизии
+	RevisionRestore = "restore"
+)
+
+// BoardRevision - снимок элементов доски. AuthorID пуст у периодических ревизий.
+type BoardRevision struct {
+	ID           int       `json:"id"`
+	BoardID      int       `json:"board_id"`
+	BoardVersion int64     `json:"board_version"`
+	Kind         string    `json:"kind"`
+	Label        string    `json:"label"`
+	AuthorID     *int      `json:"author_id"`
+	AuthorName   *string   `json:"author_name"`
+	ElementCount int       `json:"element_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type CreateBoardRevisionRequest struct {
+	Label string `json:"label" binding:"max=200"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ElementStyle - оформление элемента доски. Пустые поля означают значение по умолчанию.
+// Цвета принимаются в формате CSS: #rgb, #rrggbb, rgb(), rgba(), hsl(), hsla() или transparent.
+type ElementStyle struct {
+	Fill         string   `json:"fill,omitempty" binding:"omitempty,hexcolor|rgb|rgba|hsl|hsla|eq=transparent"`
+	Stroke       string   `json:"stroke,omitempty" binding:"omitempty,hexcolor|rgb|rgba|hsl|hsla|eq=transparent"`
+	StrokeWidth  *float64 `json:"stroke_width,omitempty" binding:"omitempty,gte=0,lte=100"`
+	Opacity      *float64 `json:"opacity,omitempty" binding:"omitempty,gte=0,lte=1"`
+	FontFamily   string   `json:"font_family,omitempty" binding:"omitempty,max=100"`
+	FontSize     *float64 `json:"font_size,omitempty" binding:"omitempty,gt=0,lte=512"`
+	FontWeight   string   `json:"font_weight,omitempty" binding:"omitempty,oneof=normal bold lighter bolder 100 200 300 400 500 600 700 800 900"`
+	TextAlign    string   `json:"text_align,omitempty" binding:"omitempty,oneof=left center right justify"`
+	CornerRadius *float64 `json:"corner_radius,omitempty" binding:"omitempty,gte=0,lte=10000"`
+}
+
+// Value сохраняет стиль в колонку jsonb
+func (s ElementStyle) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan читает стиль из колонки jsonb
+func (s *ElementStyle) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*s = ElementStyle{}
+		return nil
+	case []byte:
+		return json.Unmarshal(data, s)
+	case string:
+		return json.Unmarshal([]byte(data), s)
+	default:
+		return fmt.Errorf("unsupported style type %T", src)
+	}
+}
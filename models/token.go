@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// RefreshToken - токен обновления. Сам токен не хранится, только его хеш.
+type RefreshToken struct {
+	ID     int
+	UserID int
+	// SessionID - сессия, начатая входом и продолженная цепочкой обновлений
+	SessionID string
+	TokenHash string
+	// AccessJTI и AccessExpiresAt - токен доступа, выданный вместе с этим токеном
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	CreatedAt       time.Time
+	// UsedAt задан у токенов, обмененных на следующий токен цепочки
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// Session - вход пользователя с одного устройства
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"-"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current - сессия, из которой сделан запрос
+	Current bool `json:"current"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
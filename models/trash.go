@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TrashedElement - элемент в корзине. Вместе с ним восстанавливаются
+// коннекторы и вложенные элементы, удаленные с ним одной операцией.
+type TrashedElement struct {
+	Element    BoardElement `json:"element"`
+	BoardTitle string       `json:"board_title"`
+	DeletedAt  time.Time    `json:"deleted_at"`
+	DeletedBy  *int         `json:"deleted_by"`
+	// Related - сколько элементов было удалено вместе с этим
+	Related int `json:"related"`
+}
+
+// Trash - корзина пользователя: его удаленные доски и удаленные элементы досок,
+// которые он может редактировать
+type Trash struct {
+	Boards   []Board          `json:"boards"`
+	Elements []TrashedElement `json:"elements"`
+}
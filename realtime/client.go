@@ -0,0 +1,190 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Время на запись одного сообщения
+	writeWait = 10 * time.Second
+	// Максимальное время ожидания pong от клиента
+	pongWait = 60 * time.Second
+	// Период отправки ping, должен быть меньше pongWait
+	pingPeriod = (pongWait * 9) / 10
+	// Максимальный размер входящего сообщения
+	maxMessageSize = 4096
+	// Размер очереди исходящих сообщений клиента
+	sendBufferSize = 256
+	// Подключение без сообщений от клиента дольше этого времени считается неактивным
+	idleTimeout = 5 * time.Minute
+	// Минимальный интервал между рассылками позиции курсора одного клиента
+	cursorInterval = 50 * time.Millisecond
+)
+
+// Типы сообщений, которые клиент отправляет серверу
+const (
+	MessageCursor    = "cursor"
+	MessageHeartbeat = "heartbeat"
+)
+
+var errIdle = errors.New("connection is idle")
+
+// clientMessage - входящее сообщение от клиента
+type clientMessage struct {
+	Type string  `json:"type"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+}
+
+// Client представляет одно WebSocket-подключение к доске
+type Client struct {
+	BoardID int
+	UserID  int
+	Email   string
+	// Сессия, токеном которой открыто подключение. При её завершении хаб отключает клиента.
+	SessionID string
+
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+
+	// Время последнего сообщения от клиента, читается и пишется только в readPump
+	lastActive time.Time
+
+	cursorMu     sync.Mutex
+	cursor       Cursor
+	cursorSentAt time.Time
+	cursorTimer  *time.Timer
+}
+
+// NewClient создает клиента для подключения к доске, открытого в сессии sessionID
+func NewClient(hub *Hub, conn *websocket.Conn, boardID, userID int, email, sessionID string) *Client {
+	return &Client{
+		BoardID:   boardID,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan []byte, sendBufferSize),
+	}
+}
+
+// Serve регистрирует клиента в хабе и обслуживает подключение до его закрытия
+func (c *Client) Serve() {
+	c.hub.Register(c)
+	go c.writePump()
+	c.readPump()
+}
+
+// readPump читает сообщения клиента и закрывает подключение,
+// если клиент пропал или долго ничего не присылал
+func (c *Client) readPump() {
+	defer func() {
+		c.stopCursor()
+		c.hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	c.lastActive = time.Now()
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		if time.Since(c.lastActive) > idleTimeout {
+			return errIdle
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.lastActive = time.Now()
+
+		var message clientMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			continue
+		}
+		switch message.Type {
+		case MessageCursor:
+			c.moveCursor(message.X, message.Y)
+		case MessageHeartbeat:
+			// Достаточно обновить время активности
+		}
+	}
+}
+
+// moveCursor рассылает позицию курсора не чаще cursorInterval.
+// Промежуточные позиции отбрасываются, последняя отправляется по таймеру.
+func (c *Client) moveCursor(x, y float64) {
+	c.cursorMu.Lock()
+	defer c.cursorMu.Unlock()
+
+	c.cursor = Cursor{UserID: c.UserID, Email: c.Email, X: x, Y: y}
+	if c.cursorTimer != nil {
+		return
+	}
+
+	wait := cursorInterval - time.Since(c.cursorSentAt)
+	if wait <= 0 {
+		c.cursorSentAt = time.Now()
+		c.hub.moveCursor(c, c.cursor)
+		return
+	}
+
+	c.cursorTimer = time.AfterFunc(wait, func() {
+		c.cursorMu.Lock()
+		cursor := c.cursor
+		c.cursorTimer = nil
+		c.cursorSentAt = time.Now()
+		c.cursorMu.Unlock()
+
+		c.hub.moveCursor(c, cursor)
+	})
+}
+
+func (c *Client) stopCursor() {
+	c.cursorMu.Lock()
+	defer c.cursorMu.Unlock()
+
+	if c.cursorTimer != nil {
+		c.cursorTimer.Stop()
+		c.cursorTimer = nil
+	}
+}
+
+// writePump отправляет клиенту сообщения из очереди и периодический ping
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Типы событий, рассылаемых участникам доски
+const (
+	EventElementCreated    = "element.created"
+	EventElementUpdated    = "element.updated"
+	EventElementDeleted    = "element.deleted"
+	EventElementsReordered = "elements.reordered"
+	EventBoardDeleted      = "board.deleted"
+	EventBoardRestored     = "board.restored"
+
+	EventPresenceSnapshot = "presence.snapshot"
+	EventPresenceJoined   = "presence.joined"
+	EventPresenceLeft     = "presence.left"
+	EventCursorMoved      = "cursor.moved"
+)
+
+// Event описывает сообщение, которое получают все открывшие доску клиенты
+type Event struct {
+	Type    string      `json:"type"`
+	BoardID int         `json:"board_id"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// DeletedElement передается в событии удаления элемента
+type DeletedElement struct {
+	ID      int `json:"id"`
+	BoardID int `json:"board_id"`
+}
+
+// RestoredBoard передается в событии восстановления ревизии. Клиенты перезагружают
+// доску или догоняют её через ленту изменений.
+type RestoredBoard struct {
+	RevisionID int   `json:"revision_id"`
+	Version    int64 `json:"version"`
+}
+
+// PresenceUser описывает пользователя, который сейчас находится на доске
+type PresenceUser struct {
+	UserID   int       `json:"user_id"`
+	Email    string    `json:"email"`
+	JoinedAt time.Time `json:"joined_at"`
+	Cursor   *Cursor   `json:"cursor,omitempty"`
+}
+
+// Cursor - позиция курсора в координатах холста
+type Cursor struct {
+	UserID int     `json:"user_id"`
+	Email  string  `json:"email"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+}
+
+// room объединяет подключения одной доски.
+// Пользователь может открыть доску в нескольких вкладках,
+// поэтому присутствие считается по числу его подключений.
+type room struct {
+	clients map[*Client]struct{}
+	users   map[int]*PresenceUser
+	conns   map[int]int
+}
+
+// Hub хранит подключения и присутствие, сгруппированные по доскам.
+// Данные о присутствии живут только в памяти.
+type Hub struct {
+	mu     sync.RWMutex
+	boards map[int]*room
+}
+
+// NewHub создает пустой хаб
+func NewHub() *Hub {
+	return &Hub{boards: make(map[int]*room)}
+}
+
+// Register добавляет клиента к комнате его доски.
+// Новому клиенту отправляется список присутствующих, остальным - событие входа.
+func (h *Hub) Register(client *Client) {
+	h.mu.Lock()
+	r, ok := h.boards[client.BoardID]
+	if !ok {
+		r = &room{
+			clients: make(map[*Client]struct{}),
+			users:   make(map[int]*PresenceUser),
+			conns:   make(map[int]int),
+		}
+		h.boards[client.BoardID] = r
+	}
+	r.clients[client] = struct{}{}
+	r.conns[client.UserID]++
+	user, joined := r.users[client.UserID], false
+	if user == nil {
+		user = &PresenceUser{UserID: client.UserID, Email: client.Email, JoinedAt: time.Now()}
+		r.users[client.UserID] = user
+		joined = true
+	}
+	joinedUser := *user
+	// Очередь нового клиента пуста, поэтому снимок помещается в неё без ожидания
+	if message, err := json.Marshal(Event{Type: EventPresenceSnapshot, BoardID: client.BoardID, Payload: r.snapshot()}); err == nil {
+		select {
+		case client.send <- message:
+		default:
+		}
+	}
+	h.mu.Unlock()
+
+	if joined {
+		h.broadcastExcept(client.BoardID, Event{Type: EventPresenceJoined, Payload: joinedUser}, client)
+	}
+}
+
+// Unregister удаляет клиента и закрывает его очередь отправки.
+// Когда у пользователя не остается подключений, остальные получают событие выхода.
+func (h *Hub) Unregister(client *Client) {
+	h.mu.Lock()
+	r, ok := h.boards[client.BoardID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	if _, ok := r.clients[client]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(r.clients, client)
+	close(client.send)
+
+	var left *PresenceUser
+	r.conns[client.UserID]--
+	if r.conns[client.UserID] <= 0 {
+		left = r.users[client.UserID]
+		delete(r.conns, client.UserID)
+		delete(r.users, client.UserID)
+	}
+	if len(r.clients) == 0 {
+		delete(h.boards, client.BoardID)
+	}
+	h.mu.Unlock()
+
+	if left != nil {
+		h.Broadcast(client.BoardID, Event{Type: EventPresenceLeft, Payload: PresenceUser{UserID: left.UserID, Email: left.Email, JoinedAt: left.JoinedAt}})
+	}
+}
+
+// DisconnectSessions отключает все клиенты, открытые в перечисленных сессиях.
+// Токен проверяется только при установке подключения, поэтому после завершения
+// сессии её открытые доски нужно закрыть явно.
+func (h *Hub) DisconnectSessions(sessionIDs ...string) {
+	revoked := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		revoked[id] = true
+	}
+	h.disconnect(func(client *Client) bool { return revoked[client.SessionID] })
+}
+
+// DisconnectUser отключает все клиенты пользователя, например после выхода со всех устройств
+func (h *Hub) DisconnectUser(userID int) {
+	h.disconnect(func(client *Client) bool { return client.UserID == userID })
+}
+
+// DisconnectBoardUser отключает пользователя от доски, например после отзыва доступа.
+// Если доступ остался, клиент переподключится уже с новыми правами.
+func (h *Hub) DisconnectBoardUser(boardID, userID int) {
+	h.disconnect(func(client *Client) bool { return client.BoardID == boardID && client.UserID == userID })
+}
+
+// DisconnectBoard отключает всех участников доски, например после её удаления
+func (h *Hub) DisconnectBoard(boardID int) {
+	h.disconnect(func(client *Client) bool { return client.BoardID == boardID })
+}
+
+// disconnect снимает с регистрации подходящих клиентов. Закрытая очередь отправки
+// заставляет клиента отправить кадр закрытия и разорвать подключение.
+func (h *Hub) disconnect(match func(*Client) bool) {
+	h.mu.RLock()
+	var clients []*Client
+	for _, r := range h.boards {
+		for client := range r.clients {
+			if match(client) {
+				clients = append(clients, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.Unregister(client)
+	}
+}
+
+// Presence возвращает пользователей, которые сейчас находятся на доске
+func (h *Hub) Presence(boardID int) []PresenceUser {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	r, ok := h.boards[boardID]
+	if !ok {
+		return []PresenceUser{}
+	}
+	return r.snapshot()
+}
+
+// Broadcast отправляет событие всем клиентам доски.
+// Клиенты, не успевающие читать сообщения, отключаются.
+func (h *Hub) Broadcast(boardID int, event Event) {
+	h.broadcastExcept(boardID, event, nil)
+}
+
+// moveCursor запоминает позицию курсора пользователя и рассылает её остальным
+func (h *Hub) moveCursor(client *Client, cursor Cursor) {
+	h.mu.Lock()
+	r, ok := h.boards[client.BoardID]
+	if ok {
+		if user := r.users[client.UserID]; user != nil {
+			user.Cursor = &cursor
+		}
+	}
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	h.broadcastExcept(client.BoardID, Event{Type: EventCursorMoved, Payload: cursor}, client)
+}
+
+func (h *Hub) broadcastExcept(boardID int, event Event, except *Client) {
+	event.BoardID = boardID
+	message, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	var slow []*Client
+	if r, ok := h.boards[boardID]; ok {
+		for client := range r.clients {
+			if client == except {
+				continue
+			}
+			select {
+			case client.send <- message:
+			default:
+				slow = append(slow, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range slow {
+		h.Unregister(client)
+	}
+}
+
+// snapshot копирует список присутствующих, отсортированный по времени входа.
+// Вызывается под блокировкой хаба.
+func (r *room) snapshot() []PresenceUser {
+	users := make([]PresenceUser, 0, len(r.users))
+	for _, user := range r.users {
+		copied := *user
+		if user.Cursor != nil {
+			cursor := *user.Cursor
+			copied.Cursor = &cursor
+		}
+		users = append(users, copied)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].JoinedAt.Before(users[j].JoinedAt)
+	})
+	return users
+}
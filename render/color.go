@@ -0,0 +1,149 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseColor разбирает цвет CSS в форматах, которые принимает стиль элемента:
+// #rgb, #rgba, #rrggbb, #rrggbbaa, rgb(), rgba(), hsl(), hsla() и transparent.
+// Пустая строка и нераспознанный цвет дают fallback.
+func parseColor(value, fallback string) color.NRGBA {
+	if c, ok := cssColor(value); ok {
+		return c
+	}
+	c, _ := cssColor(fallback)
+	return c
+}
+
+func cssColor(value string) (color.NRGBA, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "transparent":
+		return color.NRGBA{}, true
+	case strings.HasPrefix(value, "#"):
+		return hexColor(value[1:])
+	case strings.HasPrefix(value, "rgb"):
+		return functionalColor(value, "rgb", rgbColor)
+	case strings.HasPrefix(value, "hsl"):
+		return functionalColor(value, "hsl", hslColor)
+	}
+	return color.NRGBA{}, false
+}
+
+func hexColor(hex string) (color.NRGBA, bool) {
+	// Короткая запись #rgb(a) - каждая цифра повторяется
+	if len(hex) == 3 || len(hex) == 4 {
+		long := make([]byte, 0, 2*len(hex))
+		for i := 0; i < len(hex); i++ {
+			long = append(long, hex[i], hex[i])
+		}
+		hex = string(long)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
+
+// functionalColor разбирает запись вида name(a, b, c) или nameA(a, b, c, alpha)
+func functionalColor(value, name string, convert func(args []string) (color.NRGBA, bool)) (color.NRGBA, bool) {
+	open := strings.IndexByte(value, '(')
+	if open < 0 || !strings.HasSuffix(value, ")") || strings.TrimSuffix(value[:open], "a") != name {
+		return color.NRGBA{}, false
+	}
+	args := strings.FieldsFunc(value[open+1:len(value)-1], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+	if len(args) != 3 && len(args) != 4 {
+		return color.NRGBA{}, false
+	}
+	c, ok := convert(args[:3])
+	if !ok {
+		return c, false
+	}
+	if len(args) == 4 {
+		alpha, ok := component(args[3], 1)
+		if !ok {
+			return c, false
+		}
+		c.A = uint8(math.Round(clamp(alpha, 0, 1) * 255))
+	}
+	return c, true
+}
+
+func rgbColor(args []string) (color.NRGBA, bool) {
+	var channels [3]uint8
+	for i, arg := range args {
+		v, ok := component(arg, 255)
+		if !ok {
+			return color.NRGBA{}, false
+		}
+		channels[i] = uint8(math.Round(clamp(v, 0, 255)))
+	}
+	return color.NRGBA{R: channels[0], G: channels[1], B: channels[2], A: 255}, true
+}
+
+func hslColor(args []string) (color.NRGBA, bool) {
+	h, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	s, ok1 := component(args[1], 1)
+	l, ok2 := component(args[2], 1)
+	if !ok1 || !ok2 {
+		return color.NRGBA{}, false
+	}
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 360
+	s, l = clamp(s, 0, 1), clamp(l, 0, 1)
+
+	// Преобразование HSL в RGB по CSS Color Module
+	q := l + s - l*s
+	if l < 0.5 {
+		q = l * (1 + s)
+	}
+	p := 2*l - q
+	channel := func(t float64) uint8 {
+		t = math.Mod(t+1, 1)
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 1.0/2:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(math.Round(v * 255))
+	}
+	return color.NRGBA{R: channel(h + 1.0/3), G: channel(h), B: channel(h - 1.0/3), A: 255}, true
+}
+
+// component разбирает число или процент. Процент переводится в долю от full.
+func component(arg string, full float64) (float64, bool) {
+	if strings.HasSuffix(arg, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		return v / 100 * full, err == nil
+	}
+	v, err := strconv.ParseFloat(arg, 64)
+	return v, err == nil
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// withOpacity умножает прозрачность цвета на opacity
+func withOpacity(c color.NRGBA, opacity float64) color.NRGBA {
+	c.A = uint8(math.Round(float64(c.A) * clamp(opacity, 0, 1)))
+	return c
+}
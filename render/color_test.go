@@ -0,0 +1,41 @@
+package render
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		want  color.NRGBA
+	}{
+		{"#abc", color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}},
+		{"#abcd", color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xdd}},
+		{"#102030", color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}},
+		{"#10203040", color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}},
+		{" #FFFFFF ", color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"transparent", color.NRGBA{}},
+		{"rgb(255, 0, 0)", color.NRGBA{R: 255, A: 255}},
+		{"rgb(100%, 50%, 0%)", color.NRGBA{R: 255, G: 128, A: 255}},
+		{"rgb(0 0 255 / 50%)", color.NRGBA{B: 255, A: 128}},
+		{"rgba(0,0,255,0.5)", color.NRGBA{B: 255, A: 128}},
+		{"rgb(300, -5, 0)", color.NRGBA{R: 255, A: 255}},
+		{"hsl(120, 100%, 50%)", color.NRGBA{G: 255, A: 255}},
+		{"hsl(-120deg, 100%, 50%)", color.NRGBA{B: 255, A: 255}},
+		{"hsla(0, 0%, 100%, 0.25)", color.NRGBA{R: 255, G: 255, B: 255, A: 64}},
+		// Нераспознанные значения заменяются запасным цветом
+		{"", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"red", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"#12345", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"#ggg", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"rgb(1, 2)", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"rgbx(1, 2, 3)", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+		{"hsl(a, 50%, 50%)", color.NRGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}},
+	}
+	for _, tt := range tests {
+		if got := parseColor(tt.value, "#333"); got != tt.want {
+			t.Errorf("parseColor(%q) = %v, ожидалось %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"math"
+	"micromiro/models"
+)
+
+// connectorPoints возвращает ломаную коннектора: начало, промежуточные точки и конец.
+// Прикрепленный конец берется на стороне элемента, для anchor auto - на ближайшей
+// к соседней точке ломаной.
+func connectorPoints(element models.BoardElement, byID map[int]*models.BoardElement) []models.Point {
+	c := element.Connector
+	sourceNext, targetPrev := endReference(c.Target, byID), endReference(c.Source, byID)
+	if n := len(c.Waypoints); n > 0 {
+		sourceNext, targetPrev = c.Waypoints[0], c.Waypoints[n-1]
+	}
+
+	points := make([]models.Point, 0, len(c.Waypoints)+2)
+	points = append(points, endPoint(c.Source, sourceNext, byID))
+	points = append(points, c.Waypoints...)
+	return append(points, endPoint(c.Target, targetPrev, byID))
+}
+
+// endReference возвращает центр прикрепленного элемента или свободную точку конца
+func endReference(end models.ConnectorEnd, byID map[int]*models.BoardElement) models.Point {
+	if element := attachedElement(end, byID); element != nil {
+		return element.AnchorPoint(models.AnchorCenter)
+	}
+	return models.Point{X: end.X, Y: end.Y}
+}
+
+func endPoint(end models.ConnectorEnd, toward models.Point, byID map[int]*models.BoardElement) models.Point {
+	element := attachedElement(end, byID)
+	if element == nil {
+		return models.Point{X: end.X, Y: end.Y}
+	}
+	if end.Anchor != "" && end.Anchor != models.AnchorAuto {
+		return element.AnchorPoint(end.Anchor)
+	}
+
+	best := element.AnchorPoint(models.AnchorTop)
+	for _, anchor := range []string{models.AnchorRight, models.AnchorBottom, models.AnchorLeft} {
+		if p := element.AnchorPoint(anchor); distance(p, toward) < distance(best, toward) {
+			best = p
+		}
+	}
+	return best
+}
+
+func attachedElement(end models.ConnectorEnd, byID map[int]*models.BoardElement) *models.BoardElement {
+	if end.ElementID == nil {
+		return nil
+	}
+	return byID[*end.ElementID]
+}
+
+// connectorStrokeWidth возвращает толщину линии коннектора, по умолчанию 2
+func connectorStrokeWidth(style models.ElementStyle) float64 {
+	return floatOr(style.StrokeWidth, 2)
+}
+
+func writeConnector(out *bufio.Writer, element models.BoardElement, byID map[int]*models.BoardElement) {
+	if element.Connector == nil {
+		return
+	}
+	style := element.Style
+	color := colorOr(style.Stroke, defaultStroke)
+	width := connectorStrokeWidth(style)
+	points := connectorPoints(element, byID)
+
+	fmt.Fprintf(out, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"/>`,
+		svgPoints(points), color, num(width))
+
+	n := len(points)
+	heads := []arrowhead{
+		newArrowhead(element.Connector.Source.Arrowhead, points[0], points[1], width),
+		newArrowhead(element.Connector.Target.Arrowhead, points[n-1], points[n-2], width),
+	}
+	for _, head := range heads {
+		switch {
+		case head.points == nil:
+		case head.filled:
+			fmt.Fprintf(out, `<polygon points="%s" fill="%s"/>`, svgPoints(head.points), color)
+		default:
+			fmt.Fprintf(out, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linejoin="round" stroke-linecap="round"/>`,
+				svgPoints(head.points), color, num(width))
+		}
+	}
+
+	if element.Connector.Label != "" {
+		mid := midpoint(points)
+		fmt.Fprintf(out, `<text x="%s" y="%s" font-family="%s" font-size="%s" fill="%s" text-anchor="middle" dominant-baseline="middle" stroke="#ffffff" stroke-width="4" paint-order="stroke">%s</text>`,
+			num(mid.X), num(mid.Y), html.EscapeString(stringOr(style.FontFamily, defaultFontFamily)), num(labelSize), defaultTextColor, html.EscapeString(element.Connector.Label))
+	}
+}
+
+// arrowhead - наконечник коннектора: закрашенный контур или открытая ломаная
+type arrowhead struct {
+	points []models.Point
+	filled bool
+}
+
+// newArrowhead строит наконечник в точке tip, направленный от точки from.
+// Для kind none и неизвестных видов points пустой.
+func newArrowhead(kind string, tip, from models.Point, width float64) arrowhead {
+	size := 6 + 2*width
+	angle := math.Atan2(tip.Y-from.Y, tip.X-from.X)
+	// at возвращает точку в системе координат наконечника: ось x направлена вдоль линии к tip
+	at := func(dx, dy float64) models.Point {
+		return models.Point{
+			X: tip.X + dx*math.Cos(angle) - dy*math.Sin(angle),
+			Y: tip.Y + dx*math.Sin(angle) + dy*math.Cos(angle),
+		}
+	}
+
+	switch kind {
+	case "arrow":
+		return arrowhead{points: []models.Point{at(-size, -size/2), at(0, 0), at(-size, size/2)}}
+	case "triangle":
+		return arrowhead{points: []models.Point{at(0, 0), at(-size, -size/2), at(-size, size/2)}, filled: true}
+	case "diamond":
+		return arrowhead{points: []models.Point{at(0, 0), at(-size/2, -size/3), at(-size, 0), at(-size/2, size/3)}, filled: true}
+	case "circle":
+		return arrowhead{points: ellipse(at(-size/2, 0), size/2, size/2), filled: true}
+	}
+	return arrowhead{}
+}
+
+// midpoint возвращает точку посередине длины ломаной
+func midpoint(points []models.Point) models.Point {
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += distance(points[i-1], points[i])
+	}
+	half := total / 2
+	for i := 1; i < len(points); i++ {
+		segment := distance(points[i-1], points[i])
+		if segment > 0 && half <= segment {
+			t := half / segment
+			return models.Point{X: points[i-1].X + (points[i].X-points[i-1].X)*t, Y: points[i-1].Y + (points[i].Y-points[i-1].Y)*t}
+		}
+		half -= segment
+	}
+	return points[0]
+}
+
+func distance(a, b models.Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
@@ -0,0 +1,398 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"micromiro/models"
+	"os"
+)
+
+// errBadFont возвращается для файла, который не удалось разобрать как шрифт TrueType
+var errBadFont = errors.New("unsupported or malformed TrueType font")
+
+// Шагов аппроксимации квадратичной кривой контура глифа отрезками
+const curveSteps = 6
+
+// Font - шрифт TrueType (.ttf), которым в PNG и PDF рисуется текст. Глифы
+// переводятся в контуры, поэтому шрифт не встраивается в файлы выгрузки.
+type Font struct {
+	unitsPerEm  float64
+	longLoca    bool
+	numGlyphs   int
+	numHMetrics int
+	loca        []byte
+	glyf        []byte
+	hmtx        []byte
+	cmap        []byte
+	cmapFormat  int
+}
+
+// LoadFont читает шрифт TrueType из файла. Коллекции шрифтов (.ttc) и шрифты
+// с контурами CFF (.otf) не поддерживаются.
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	font, err := parseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return font, nil
+}
+
+func parseFont(data []byte) (*Font, error) {
+	if len(data) < 12 || u32(data, 0) != 0x00010000 && string(data[:4]) != "true" {
+		return nil, errBadFont
+	}
+	tables := map[string][]byte{}
+	for i := 0; i < u16(data, 4); i++ {
+		record := 12 + 16*i
+		offset, length := u32(data, record+8), u32(data, record+12)
+		if record+16 > len(data) || offset+length > len(data) {
+			return nil, errBadFont
+		}
+		tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "maxp", "hhea", "hmtx", "loca", "glyf", "cmap"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("%w: no %s table", errBadFont, tag)
+		}
+	}
+
+	font := &Font{
+		unitsPerEm:  float64(u16(tables["head"], 18)),
+		longLoca:    u16(tables["head"], 50) != 0,
+		numGlyphs:   u16(tables["maxp"], 4),
+		numHMetrics: u16(tables["hhea"], 34),
+		loca:        tables["loca"],
+		glyf:        tables["glyf"],
+		hmtx:        tables["hmtx"],
+	}
+	if font.unitsPerEm == 0 || font.numHMetrics == 0 {
+		return nil, errBadFont
+	}
+
+	// Подходящая таблица символов: полный Unicode (формат 12) или базовая плоскость (формат 4)
+	cmap := tables["cmap"]
+	best := 0
+	for i := 0; i < u16(cmap, 2); i++ {
+		record := 4 + 8*i
+		platform, encoding, offset := u16(cmap, record), u16(cmap, record+2), u32(cmap, record+4)
+		unicode := platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)
+		if !unicode || offset >= len(cmap) {
+			continue
+		}
+		format := u16(cmap, offset)
+		if rank := map[int]int{4: 1, 12: 2}[format]; rank > best {
+			best, font.cmap, font.cmapFormat = rank, cmap[offset:], format
+		}
+	}
+	if font.cmap == nil {
+		return nil, fmt.Errorf("%w: no unicode cmap", errBadFont)
+	}
+	return font, nil
+}
+
+// index возвращает номер глифа символа, 0 - глиф отсутствующего символа
+func (f *Font) index(r rune) int {
+	c := int(r)
+	switch f.cmapFormat {
+	case 4:
+		segments := u16(f.cmap, 6) / 2
+		for i := 0; i < segments; i++ {
+			if u16(f.cmap, 14+2*i) < c {
+				continue
+			}
+			start := u16(f.cmap, 16+2*segments+2*i)
+			if start > c {
+				return 0
+			}
+			delta := u16(f.cmap, 16+4*segments+2*i)
+			rangeAt := 16 + 6*segments + 2*i
+			rangeOffset := u16(f.cmap, rangeAt)
+			if rangeOffset == 0 {
+				return (c + delta) & 0xffff
+			}
+			glyph := u16(f.cmap, rangeAt+rangeOffset+2*(c-start))
+			if glyph == 0 {
+				return 0
+			}
+			return (glyph + delta) & 0xffff
+		}
+	case 12:
+		for i := 0; i < u32(f.cmap, 12); i++ {
+			group := 16 + 12*i
+			if group+12 > len(f.cmap) {
+				break
+			}
+			start, end := u32(f.cmap, group), u32(f.cmap, group+4)
+			if c >= start && c <= end {
+				return u32(f.cmap, group+8) + c - start
+			}
+		}
+	}
+	return 0
+}
+
+// advance возвращает ширину глифа в единицах шрифта
+func (f *Font) advance(glyph int) float64 {
+	if glyph >= f.numHMetrics {
+		glyph = f.numHMetrics - 1
+	}
+	return float64(u16(f.hmtx, 4*glyph))
+}
+
+// width возвращает ширину строки при кегле size
+func (f *Font) width(text string, size float64) float64 {
+	total := 0.0
+	for _, r := range text {
+		total += f.advance(f.index(r))
+	}
+	return total * size / f.unitsPerEm
+}
+
+// outline возвращает контуры строки в координатах холста: начало строки в x,
+// базовая линия на высоте baseline
+func (f *Font) outline(text string, x, baseline, size float64) [][]models.Point {
+	scale := size / f.unitsPerEm
+	contours := [][]models.Point{}
+	for _, r := range text {
+		glyph := f.index(r)
+		for _, contour := range f.glyphContours(glyph, 0) {
+			points := flattenContour(contour)
+			for i := range points {
+				// Ось y шрифта направлена вверх, холста - вниз
+				points[i] = models.Point{X: x + points[i].X*scale, Y: baseline - points[i].Y*scale}
+			}
+			contours = append(contours, points)
+		}
+		x += f.advance(glyph) * scale
+	}
+	return contours
+}
+
+// glyphPoint - точка контура глифа в единицах шрифта. Точки не на кривой - контрольные
+// точки квадратичных кривых.
+type glyphPoint struct {
+	x, y    float64
+	onCurve bool
+}
+
+// glyphContours разбирает контуры глифа. Составные глифы собираются из частей,
+// глубина вложенности ограничена.
+func (f *Font) glyphContours(glyph, depth int) [][]glyphPoint {
+	if glyph < 0 || glyph >= f.numGlyphs || depth > 8 {
+		return nil
+	}
+	var start, end int
+	if f.longLoca {
+		start, end = u32(f.loca, 4*glyph), u32(f.loca, 4*glyph+4)
+	} else {
+		start, end = 2*u16(f.loca, 2*glyph), 2*u16(f.loca, 2*glyph+2)
+	}
+	if start >= end || end > len(f.glyf) {
+		return nil
+	}
+	data := f.glyf[start:end]
+
+	contourCount := int(int16(u16(data, 0)))
+	if contourCount < 0 {
+		return f.compositeContours(data, depth)
+	}
+	return simpleContours(data, contourCount)
+}
+
+func simpleContours(data []byte, contourCount int) [][]glyphPoint {
+	ends := make([]int, contourCount)
+	for i := range ends {
+		ends[i] = u16(data, 10+2*i)
+	}
+	if contourCount == 0 {
+		return nil
+	}
+	pointCount := ends[contourCount-1] + 1
+	p := 12 + 2*contourCount + u16(data, 10+2*contourCount)
+
+	// Флаги точек с повторами
+	flags := make([]byte, 0, pointCount)
+	for len(flags) < pointCount {
+		if p >= len(data) {
+			return nil
+		}
+		flag := data[p]
+		p++
+		flags = append(flags, flag)
+		if flag&0x08 != 0 && p < len(data) {
+			for repeat := int(data[p]); repeat > 0 && len(flags) < pointCount; repeat-- {
+				flags = append(flags, flag)
+			}
+			p++
+		}
+	}
+
+	// Координаты записаны приращениями: короткими (байт и знак во флаге) или int16
+	points := make([]glyphPoint, pointCount)
+	readAxis := func(short, same byte, set func(i int, v float64)) {
+		v := 0
+		for i, flag := range flags {
+			switch {
+			case flag&short != 0:
+				d := int(byteAt(data, p))
+				p++
+				if flag&same == 0 {
+					d = -d
+				}
+				v += d
+			case flag&same == 0:
+				v += int(int16(u16(data, p)))
+				p += 2
+			}
+			set(i, float64(v))
+		}
+	}
+	readAxis(0x02, 0x10, func(i int, v float64) { points[i].x = v })
+	readAxis(0x04, 0x20, func(i int, v float64) { points[i].y = v })
+	for i, flag := range flags {
+		points[i].onCurve = flag&0x01 != 0
+	}
+
+	contours := make([][]glyphPoint, 0, contourCount)
+	from := 0
+	for _, end := range ends {
+		if end < from || end >= pointCount {
+			return contours
+		}
+		contours = append(contours, points[from:end+1])
+		from = end + 1
+	}
+	return contours
+}
+
+func (f *Font) compositeContours(data []byte, depth int) [][]glyphPoint {
+	contours := [][]glyphPoint{}
+	for p := 10; p+4 <= len(data); {
+		flags, component := u16(data, p), u16(data, p+2)
+		p += 4
+
+		var dx, dy float64
+		if flags&0x0001 != 0 {
+			dx, dy = float64(int16(u16(data, p))), float64(int16(u16(data, p+2)))
+			p += 4
+		} else {
+			dx, dy = float64(int8(byteAt(data, p))), float64(int8(byteAt(data, p+1)))
+			p += 2
+		}
+		// Привязка частей по номерам точек встречается редко и не поддерживается
+		if flags&0x0002 == 0 {
+			dx, dy = 0, 0
+		}
+
+		a, b, c, d := 1.0, 0.0, 0.0, 1.0
+		switch {
+		case flags&0x0008 != 0:
+			a = f2dot14(u16(data, p))
+			d = a
+			p += 2
+		case flags&0x0040 != 0:
+			a, d = f2dot14(u16(data, p)), f2dot14(u16(data, p+2))
+			p += 4
+		case flags&0x0080 != 0:
+			a, b, c, d = f2dot14(u16(data, p)), f2dot14(u16(data, p+2)), f2dot14(u16(data, p+4)), f2dot14(u16(data, p+6))
+			p += 8
+		}
+
+		for _, contour := range f.glyphContours(component, depth+1) {
+			moved := make([]glyphPoint, len(contour))
+			for i, pt := range contour {
+				moved[i] = glyphPoint{x: a*pt.x + c*pt.y + dx, y: b*pt.x + d*pt.y + dy, onCurve: pt.onCurve}
+			}
+			contours = append(contours, moved)
+		}
+
+		// Флаг MORE_COMPONENTS
+		if flags&0x0020 == 0 {
+			break
+		}
+	}
+	return contours
+}
+
+// flattenContour заменяет кривые контура отрезками. Между соседними контрольными точками
+// подразумевается точка на кривой посередине.
+func flattenContour(contour []glyphPoint) []models.Point {
+	n := len(contour)
+	if n < 2 {
+		return nil
+	}
+	expanded := make([]glyphPoint, 0, 2*n)
+	for i, pt := range contour {
+		expanded = append(expanded, pt)
+		next := contour[(i+1)%n]
+		if !pt.onCurve && !next.onCurve {
+			expanded = append(expanded, glyphPoint{x: (pt.x + next.x) / 2, y: (pt.y + next.y) / 2, onCurve: true})
+		}
+	}
+	// Обход начинается с точки на кривой
+	for i, pt := range expanded {
+		if pt.onCurve {
+			expanded = append(expanded[i:], expanded[:i]...)
+			break
+		}
+	}
+
+	m := len(expanded)
+	start := expanded[0]
+	points := []models.Point{{X: start.x, Y: start.y}}
+	last := start
+	for i := 1; i <= m; {
+		pt := expanded[i%m]
+		if pt.onCurve {
+			if i < m {
+				points = append(points, models.Point{X: pt.x, Y: pt.y})
+			}
+			last = pt
+			i++
+			continue
+		}
+		end := expanded[(i+1)%m]
+		for step := 1; step <= curveSteps; step++ {
+			t := float64(step) / curveSteps
+			u := 1 - t
+			points = append(points, models.Point{
+				X: u*u*last.x + 2*u*t*pt.x + t*t*end.x,
+				Y: u*u*last.y + 2*u*t*pt.y + t*t*end.y,
+			})
+		}
+		last = end
+		i += 2
+	}
+	return points
+}
+
+func f2dot14(v int) float64 {
+	return float64(int16(v)) / (1 << 14)
+}
+
+// Чтение чисел big-endian. За пределами данных возвращается 0, чтобы поврежденный
+// шрифт давал пустые глифы, а не панику.
+func u16(data []byte, offset int) int {
+	if offset < 0 || offset+2 > len(data) {
+		return 0
+	}
+	return int(data[offset])<<8 | int(data[offset+1])
+}
+
+func u32(data []byte, offset int) int {
+	if offset < 0 || offset+4 > len(data) {
+		return 0
+	}
+	return int(uint32(data[offset])<<24 | uint32(data[offset+1])<<16 | uint32(data[offset+2])<<8 | uint32(data[offset+3]))
+}
+
+func byteAt(data []byte, offset int) byte {
+	if offset < 0 || offset >= len(data) {
+		return 0
+	}
+	return data[offset]
+}
@@ -0,0 +1,210 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"micromiro/models"
+	"sort"
+	"strconv"
+)
+
+// Наибольшая сторона страницы PDF в пунктах
+const maxPageSide = 14400
+
+// pdfPage - элементы одной страницы документа и её область холста
+type pdfPage struct {
+	elements []models.BoardElement
+	view     rect
+}
+
+// PDF рисует доску в векторный документ: по странице на рамку в порядке чтения,
+// сверху вниз и слева направо. С FrameID или Region, а также для доски без рамок
+// получается одна страница. Пункт страницы соответствует 1/Scale единицы холста.
+func (r *Renderer) PDF(w io.Writer, elements []models.BoardElement, params models.RenderParams) error {
+	byID := indexElements(elements)
+	pages, err := pdfPages(elements, byID, params)
+	if err != nil {
+		return err
+	}
+	scale := scaleOf(params)
+
+	alphas := map[uint8]bool{}
+	contents := make([][]byte, 0, len(pages))
+	for _, page := range pages {
+		if page.view.Width*scale > maxPageSide || page.view.Height*scale > maxPageSide {
+			return ErrImageTooLarge
+		}
+		content, err := r.pageContent(page, byID, scale, params.Background, alphas)
+		if err != nil {
+			return err
+		}
+		contents = append(contents, content)
+	}
+
+	// Объекты: 1 - каталог, 2 - дерево страниц, 3 - общие ресурсы, далее пары страница и её содержимое
+	doc := &pdfWriter{out: bufio.NewWriter(w)}
+	doc.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	doc.object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := &bytes.Buffer{}
+	for i := range pages {
+		fmt.Fprintf(kids, "%d 0 R ", 4+2*i)
+	}
+	doc.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(pages)))
+	doc.object(pdfResources(alphas))
+	for i, page := range pages {
+		doc.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources 3 0 R /Contents %d 0 R >>",
+			num(page.view.Width*scale), num(page.view.Height*scale), 5+2*i))
+		doc.stream(contents[i])
+	}
+	return doc.finish()
+}
+
+func pdfPages(elements []models.BoardElement, byID map[int]*models.BoardElement, params models.RenderParams) ([]pdfPage, error) {
+	if params.FrameID != 0 || params.Region != nil {
+		visible, view, err := selectElements(elements, byID, params)
+		if err != nil {
+			return nil, err
+		}
+		return []pdfPage{{elements: visible, view: view}}, nil
+	}
+
+	pages := []pdfPage{}
+	for _, frame := range frames(elements) {
+		visible, view, err := selectElements(elements, byID, models.RenderParams{FrameID: frame.ID})
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, pdfPage{elements: visible, view: view})
+	}
+	if len(pages) == 0 {
+		visible, view, err := selectElements(elements, byID, params)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, pdfPage{elements: visible, view: view})
+	}
+	return pages, nil
+}
+
+// pageContent пишет команды рисования страницы и сжимает их. Использованные уровни
+// прозрачности добавляются в alphas для общих ресурсов документа.
+func (r *Renderer) pageContent(page pdfPage, byID map[int]*models.BoardElement, scale float64, background string, alphas map[uint8]bool) ([]byte, error) {
+	var content bytes.Buffer
+	view := page.view
+	// Ось y страницы направлена вверх: переворачиваем и переносим область холста в начало страницы
+	fmt.Fprintf(&content, "%s 0 0 %s %s %s cm\n", num(scale), num(-scale), num(-view.X*scale), num((view.Height+view.Y)*scale))
+	fmt.Fprintf(&content, "%s %s %s %s re W n\n", num(view.X), num(view.Y), num(view.Width), num(view.Height))
+	if background != "" {
+		outline := roundedRect(view.X, view.Y, view.Width, view.Height, 0)
+		writePDFPaint(&content, [][]models.Point{outline}, true, parseColor(background, defaultFill), 0, alphas)
+	}
+	for _, s := range r.scene(page.elements, byID) {
+		if s.closed && s.fill.A > 0 {
+			writePDFPaint(&content, s.contours, true, s.fill, 0, alphas)
+		}
+		if s.stroke.A > 0 && s.strokeWidth > 0 {
+			writePDFPaint(&content, s.contours, s.closed, s.stroke, s.strokeWidth, alphas)
+		}
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(content.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// writePDFPaint заливает контуры (strokeWidth = 0) или обводит их линией толщины strokeWidth
+func writePDFPaint(out *bytes.Buffer, contours [][]models.Point, closed bool, c color.NRGBA, strokeWidth float64, alphas map[uint8]bool) {
+	out.WriteString("q\n")
+	if c.A < 255 {
+		alphas[c.A] = true
+		fmt.Fprintf(out, "/A%d gs\n", c.A)
+	}
+	operator, paint := "rg", "f"
+	if strokeWidth > 0 {
+		operator, paint = "RG", "S"
+		fmt.Fprintf(out, "%s w 1 J 1 j\n", num(strokeWidth))
+	}
+	fmt.Fprintf(out, "%s %s %s %s\n", colorComponent(c.R), colorComponent(c.G), colorComponent(c.B), operator)
+	for _, contour := range contours {
+		for i, p := range contour {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(out, "%s %s %s\n", num(p.X), num(p.Y), op)
+		}
+		if closed {
+			out.WriteString("h\n")
+		}
+	}
+	out.WriteString(paint + "\nQ\n")
+}
+
+// pdfResources описывает уровни прозрачности, использованные на страницах
+func pdfResources(alphas map[uint8]bool) string {
+	levels := make([]int, 0, len(alphas))
+	for alpha := range alphas {
+		levels = append(levels, int(alpha))
+	}
+	sort.Ints(levels)
+	var states bytes.Buffer
+	for _, alpha := range levels {
+		value := colorComponent(uint8(alpha))
+		fmt.Fprintf(&states, "/A%d << /ca %s /CA %s >> ", alpha, value, value)
+	}
+	return fmt.Sprintf("<< /ExtGState << %s>> >>", states.String())
+}
+
+func colorComponent(v uint8) string {
+	return strconv.FormatFloat(float64(v)/255, 'f', 3, 64)
+}
+
+// pdfWriter пишет объекты документа по порядку и запоминает их смещения для таблицы xref
+type pdfWriter struct {
+	out     *bufio.Writer
+	written int
+	offsets []int
+}
+
+func (p *pdfWriter) write(s string) {
+	n, _ := p.out.WriteString(s)
+	p.written += n
+}
+
+func (p *pdfWriter) object(body string) {
+	p.begin()
+	p.write(body + "\nendobj\n")
+}
+
+func (p *pdfWriter) stream(data []byte) {
+	p.begin()
+	p.write(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n", len(data)))
+	n, _ := p.out.Write(data)
+	p.written += n
+	p.write("\nendstream\nendobj\n")
+}
+
+func (p *pdfWriter) begin() {
+	p.offsets = append(p.offsets, p.written)
+	p.write(fmt.Sprintf("%d 0 obj\n", len(p.offsets)))
+}
+
+func (p *pdfWriter) finish() error {
+	xref := p.written
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1))
+	for _, offset := range p.offsets {
+		p.write(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref))
+	return p.out.Flush()
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"micromiro/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	elements := []models.BoardElement{
+		{ID: 1, Type: models.ElementTypeFrame, PositionX: 300, Width: 200, Height: 100},
+		{ID: 2, Type: models.ElementTypeFrame, PositionX: 10, Width: 100, Height: 50, ZIndex: 1},
+		{ID: 3, Type: models.ElementTypeFrame, PositionY: 200, Width: 10, Height: 10, ZIndex: 2},
+		{ID: 4, Type: "rectangle", PositionX: 20, PositionY: 10, Width: 20, Height: 20, ZIndex: 3, ParentID: ref(2),
+			Style: models.ElementStyle{Fill: "#ff0000", Opacity: floatPtr(0.5)}},
+	}
+
+	tests := []struct {
+		name   string
+		params models.RenderParams
+		// boxes - размеры страниц в порядке чтения
+		boxes []string
+	}{
+		{"страница на рамку", models.RenderParams{}, []string{"100 50", "200 100", "10 10"}},
+		{"одна рамка в масштабе", models.RenderParams{FrameID: 2, Scale: 2}, []string{"200 100"}},
+		{"область", models.RenderParams{Region: &models.RenderRegion{Width: 30, Height: 40}}, []string{"30 40"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := (&Renderer{}).PDF(&out, elements, tt.params); err != nil {
+				t.Fatalf("PDF: %v", err)
+			}
+			doc := out.Bytes()
+			checkPDFStructure(t, doc)
+
+			boxes := regexp.MustCompile(`/MediaBox \[0 0 ([\d.]+ [\d.]+)\]`).FindAllSubmatch(doc, -1)
+			if len(boxes) != len(tt.boxes) {
+				t.Fatalf("страниц %d, ожидалось %d", len(boxes), len(tt.boxes))
+			}
+			for i, box := range boxes {
+				if string(box[1]) != tt.boxes[i] {
+					t.Errorf("страница %d размером %s, ожидалось %s", i+1, box[1], tt.boxes[i])
+				}
+			}
+			if !bytes.Contains(doc, []byte(`/Count `+strconv.Itoa(len(tt.boxes))+` >>`)) {
+				t.Errorf("дерево страниц не содержит /Count %d", len(tt.boxes))
+			}
+		})
+	}
+
+	t.Run("содержимое страницы", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := (&Renderer{}).PDF(&out, elements, models.RenderParams{FrameID: 2, Background: "#00ff00"}); err != nil {
+			t.Fatalf("PDF: %v", err)
+		}
+		doc := out.Bytes()
+		if !bytes.Contains(doc, []byte("/A128 << /ca 0.502 /CA 0.502 >>")) {
+			t.Error("нет состояния прозрачности для полупрозрачного элемента")
+		}
+		content := pdfContent(t, doc)
+		for _, want := range []string{
+			// Переворот оси y и перенос рамки в начало страницы
+			"1 0 0 -1 -10 50 cm\n10 0 100 50 re W n\n",
+			// Фон и заливка элемента
+			"0.000 1.000 0.000 rg\n",
+			"/A128 gs\n1.000 0.000 0.000 rg\n",
+		} {
+			if !strings.Contains(content, want) {
+				t.Errorf("содержимое страницы не содержит %q:\n%s", want, content)
+			}
+		}
+	})
+
+	t.Run("страница больше предела", func(t *testing.T) {
+		params := models.RenderParams{Region: &models.RenderRegion{Width: maxPageSide + 1, Height: 10}}
+		if err := (&Renderer{}).PDF(io.Discard, elements, params); !errors.Is(err, ErrImageTooLarge) {
+			t.Errorf("ошибка %v, ожидалось %v", err, ErrImageTooLarge)
+		}
+	})
+}
+
+// checkPDFStructure проверяет заголовок, таблицу xref и трейлер документа
+func checkPDFStructure(t *testing.T, doc []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatal("документ не начинается с заголовка PDF или не заканчивается маркером конца")
+	}
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if match == nil {
+		t.Fatal("нет startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d не указывает на таблицу xref", xref)
+	}
+	lines := strings.Split(string(doc[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	if !strings.Contains(string(doc[xref:]), "/Size "+strconv.Itoa(count)+" ") {
+		t.Errorf("/Size трейлера не совпадает с числом объектов %d", count)
+	}
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(strings.Fields(lines[2+i])[0])
+		if want := strconv.Itoa(i) + " 0 obj\n"; !bytes.HasPrefix(doc[offset:], []byte(want)) {
+			t.Errorf("смещение объекта %d в xref указывает не на его начало", i)
+		}
+	}
+}
+
+// pdfContent распаковывает поток содержимого первой страницы
+func pdfContent(t *testing.T, doc []byte) string {
+	t.Helper()
+	match := regexp.MustCompile(`/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindSubmatchIndex(doc)
+	if match == nil {
+		t.Fatal("нет потока содержимого страницы")
+	}
+	length, _ := strconv.Atoi(string(doc[match[2]:match[3]]))
+	zr, err := zlib.NewReader(bytes.NewReader(doc[match[1] : match[1]+length]))
+	if err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("zlib: %v", err)
+	}
+	return string(content)
+}
@@ -0,0 +1,263 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"micromiro/models"
+	"sort"
+)
+
+// Ограничения размера растровой картинки: сторона и число пикселей
+const (
+	maxImageSide   = 16384
+	maxImagePixels = 40_000_000
+)
+
+// Подстрок на пиксель при сглаживании краев
+const subsamples = 4
+
+// PNG рисует элементы доски в растровую картинку и пишет её в w.
+// Пиксель картинки соответствует 1/Scale единицы холста.
+func (r *Renderer) PNG(w io.Writer, elements []models.BoardElement, params models.RenderParams) error {
+	byID := indexElements(elements)
+	visible, view, err := selectElements(elements, byID, params)
+	if err != nil {
+		return err
+	}
+	img, err := r.raster(visible, byID, view, scaleOf(params), params.Background)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// ImageSize возвращает размер в пикселях картинки, которую нарисует PNG, не рисуя её.
+// Ошибки те же, что у PNG.
+func ImageSize(elements []models.BoardElement, params models.RenderParams) (int, int, error) {
+	byID := indexElements(elements)
+	_, view, err := selectElements(elements, byID, params)
+	if err != nil {
+		return 0, 0, err
+	}
+	return rasterSize(view, scaleOf(params))
+}
+
+// rasterSize возвращает размер картинки области view или ErrImageTooLarge
+func rasterSize(view rect, scale float64) (int, int, error) {
+	width, height := math.Ceil(view.Width*scale), math.Ceil(view.Height*scale)
+	if width > maxImageSide || height > maxImageSide || width*height > maxImagePixels {
+		return 0, 0, ErrImageTooLarge
+	}
+	return int(math.Max(width, 1)), int(math.Max(height, 1)), nil
+}
+
+// raster рисует элементы в картинку области view. Пустой background - прозрачный фон.
+func (r *Renderer) raster(elements []models.BoardElement, byID map[int]*models.BoardElement, view rect, scale float64, background string) (*image.RGBA, error) {
+	width, height, err := rasterSize(view, scale)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	r.paint(img, elements, byID, view, scale, background)
+	return img, nil
+}
+
+// paint рисует элементы области view на всю картинку img
+func (r *Renderer) paint(img *image.RGBA, elements []models.BoardElement, byID map[int]*models.BoardElement, view rect, scale float64, background string) {
+	if background != "" {
+		draw.Draw(img, img.Bounds(), image.NewUniform(parseColor(background, defaultFill)), image.Point{}, draw.Src)
+	}
+
+	ras := newRasterizer(img, view, scale)
+	for _, s := range r.scene(elements, byID) {
+		if s.closed && s.fill.A > 0 {
+			ras.fill(s.contours, s.fill)
+		}
+		if s.stroke.A > 0 && s.strokeWidth > 0 {
+			ras.fill(strokeOutline(s.contours, s.closed, s.strokeWidth, scale), s.stroke)
+		}
+	}
+}
+
+// rasterizer закрашивает контуры со сглаживанием по правилу ненулевой обмотки
+type rasterizer struct {
+	img   *image.RGBA
+	view  rect
+	scale float64
+	// cover - доля покрытия пикселей текущей строки
+	cover []float32
+}
+
+func newRasterizer(img *image.RGBA, view rect, scale float64) *rasterizer {
+	return &rasterizer{img: img, view: view, scale: scale, cover: make([]float32, img.Bounds().Dx()+1)}
+}
+
+type edge struct {
+	x0, y0, x1, y1 float64
+	// dir - направление обхода: +1 сверху вниз, -1 снизу вверх
+	dir int
+}
+
+type crossing struct {
+	x   float64
+	dir int
+}
+
+func (r *rasterizer) fill(contours [][]models.Point, c color.NRGBA) {
+	edges := []edge{}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, contour := range contours {
+		for i := range contour {
+			p, q := r.pixel(contour[i]), r.pixel(contour[(i+1)%len(contour)])
+			if p.Y == q.Y {
+				continue
+			}
+			dir := 1
+			if p.Y > q.Y {
+				p, q, dir = q, p, -1
+			}
+			edges = append(edges, edge{x0: p.X, y0: p.Y, x1: q.X, y1: q.Y, dir: dir})
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, q.Y)
+		}
+	}
+	if len(edges) == 0 {
+		return
+	}
+
+	height := r.img.Bounds().Dy()
+	top, bottom := int(math.Max(math.Floor(minY), 0)), int(math.Min(math.Ceil(maxY), float64(height)))
+	crossings := []crossing{}
+	for y := top; y < bottom; y++ {
+		lo, hi := len(r.cover), -1
+		for k := 0; k < subsamples; k++ {
+			sy := float64(y) + (float64(k)+0.5)/subsamples
+			crossings = crossings[:0]
+			for _, e := range edges {
+				if sy >= e.y0 && sy < e.y1 {
+					crossings = append(crossings, crossing{x: e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), dir: e.dir})
+				}
+			}
+			sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+
+			winding, start := 0, 0.0
+			for _, cr := range crossings {
+				before := winding
+				winding += cr.dir
+				switch {
+				case before == 0 && winding != 0:
+					start = cr.x
+				case before != 0 && winding == 0:
+					a, b := r.span(start, cr.x)
+					if a < lo {
+						lo = a
+					}
+					if b > hi {
+						hi = b
+					}
+				}
+			}
+		}
+		r.blend(y, lo, hi, c)
+	}
+}
+
+// span добавляет покрытие одной подстроки на отрезке [a, b) и возвращает затронутые пиксели
+func (r *rasterizer) span(a, b float64) (int, int) {
+	width := float64(len(r.cover) - 1)
+	a, b = math.Max(a, 0), math.Min(b, width)
+	if a >= b {
+		return len(r.cover), -1
+	}
+	const weight = 1.0 / subsamples
+	ia, ib := int(a), int(b)
+	if ia == ib {
+		r.cover[ia] += float32((b - a) * weight)
+		return ia, ia
+	}
+	r.cover[ia] += float32((float64(ia+1) - a) * weight)
+	for i := ia + 1; i < ib; i++ {
+		r.cover[i] += weight
+	}
+	r.cover[ib] += float32((b - float64(ib)) * weight)
+	return ia, ib
+}
+
+// blend накладывает цвет на пиксели строки y по накопленному покрытию и обнуляет его
+func (r *rasterizer) blend(y, lo, hi int, c color.NRGBA) {
+	if last := r.img.Bounds().Dx() - 1; hi > last {
+		hi = last
+	}
+	for x := lo; x <= hi; x++ {
+		coverage := math.Min(float64(r.cover[x]), 1)
+		r.cover[x] = 0
+		if coverage <= 0 {
+			continue
+		}
+		alpha := coverage * float64(c.A) / 255
+		i := r.img.PixOffset(x, y)
+		pix := r.img.Pix[i : i+4 : i+4]
+		// Картинка хранит цвета, умноженные на альфу
+		pix[0] = uint8(float64(c.R)*alpha + float64(pix[0])*(1-alpha) + 0.5)
+		pix[1] = uint8(float64(c.G)*alpha + float64(pix[1])*(1-alpha) + 0.5)
+		pix[2] = uint8(float64(c.B)*alpha + float64(pix[2])*(1-alpha) + 0.5)
+		pix[3] = uint8(255*alpha + float64(pix[3])*(1-alpha) + 0.5)
+	}
+}
+
+// pixel переводит точку холста в координаты картинки
+func (r *rasterizer) pixel(p models.Point) models.Point {
+	return models.Point{X: (p.X - r.view.X) * r.scale, Y: (p.Y - r.view.Y) * r.scale}
+}
+
+// strokeOutline заменяет обводку ломаных контурами для заливки: прямоугольник на каждый
+// отрезок и круг в каждой вершине для скругленных стыков и концов. Все контуры обходятся
+// в одну сторону, поэтому перекрытия не вычитаются друг из друга.
+func strokeOutline(lines [][]models.Point, closed bool, width, scale float64) [][]models.Point {
+	half := width / 2
+	// Круги в вершинах тоньше пикселя незаметны
+	joins := half*scale >= 1
+	outline := [][]models.Point{}
+	for _, line := range lines {
+		n := len(line)
+		segments := n - 1
+		if closed {
+			segments = n
+		}
+		for i := 0; i < segments; i++ {
+			p, q := line[i], line[(i+1)%n]
+			length := math.Hypot(q.X-p.X, q.Y-p.Y)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -(q.Y-p.Y)/length*half, (q.X-p.X)/length*half
+			outline = append(outline, oriented([]models.Point{
+				{X: p.X + nx, Y: p.Y + ny}, {X: q.X + nx, Y: q.Y + ny}, {X: q.X - nx, Y: q.Y - ny}, {X: p.X - nx, Y: p.Y - ny},
+			}))
+		}
+		if joins {
+			for _, p := range line {
+				outline = append(outline, oriented(ellipse(p, half, half)))
+			}
+		}
+	}
+	return outline
+}
+
+// oriented возвращает контур, обходящий область по часовой стрелке на экране
+func oriented(contour []models.Point) []models.Point {
+	area := 0.0
+	for i, p := range contour {
+		q := contour[(i+1)%len(contour)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	if area < 0 {
+		for i, j := 0, len(contour)-1; i < j; i, j = i+1, j-1 {
+			contour[i], contour[j] = contour[j], contour[i]
+		}
+	}
+	return contour
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"micromiro/models"
+	"testing"
+)
+
+func TestImageSize(t *testing.T) {
+	rectangle := []models.BoardElement{{ID: 1, Type: "rectangle", Width: 100, Height: 50}}
+
+	tests := []struct {
+		name          string
+		elements      []models.BoardElement
+		params        models.RenderParams
+		width, height int
+		err           error
+	}{
+		{"пустая доска", nil, models.RenderParams{}, 40, 40, nil},
+		{"отступ вокруг элементов", rectangle, models.RenderParams{}, 140, 90, nil},
+		{"масштаб округляется вверх", rectangle, models.RenderParams{Scale: 0.5}, 70, 45, nil},
+		{"пустая область", rectangle, models.RenderParams{Region: &models.RenderRegion{}}, 1, 1, nil},
+		{"сторона больше предела", rectangle, models.RenderParams{Region: &models.RenderRegion{Width: maxImageSide + 1, Height: 1}}, 0, 0, ErrImageTooLarge},
+		{"пикселей больше предела", rectangle, models.RenderParams{Region: &models.RenderRegion{Width: 10000, Height: 10000}}, 0, 0, ErrImageTooLarge},
+		{"нет рамки", rectangle, models.RenderParams{FrameID: 1}, 0, 0, ErrFrameNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, err := ImageSize(tt.elements, tt.params)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалось %v", err, tt.err)
+			}
+			if width != tt.width || height != tt.height {
+				t.Errorf("размер %dx%d, ожидалось %dx%d", width, height, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestPNG(t *testing.T) {
+	elements := []models.BoardElement{
+		{ID: 1, Type: "rectangle", Width: 100, Height: 50, Style: models.ElementStyle{Fill: "#ff0000"}},
+		{ID: 2, Type: "rectangle", PositionX: 60, Width: 40, Height: 50, ZIndex: 1, Style: models.ElementStyle{Fill: "#0000ff", Opacity: floatPtr(0.5)}},
+	}
+
+	var out bytes.Buffer
+	params := models.RenderParams{Region: &models.RenderRegion{X: -10, Y: -10, Width: 120, Height: 70}, Scale: 2}
+	if err := (&Renderer{}).PNG(&out, elements, params); err != nil {
+		t.Fatalf("PNG: %v", err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != 240 || size.Y != 140 {
+		t.Fatalf("размер %v, ожидалось 240x140", size)
+	}
+
+	tests := []struct {
+		name string
+		x, y int
+		want color.NRGBA
+	}{
+		{"фон прозрачный", 2, 2, color.NRGBA{}},
+		{"заливка", 60, 70, color.NRGBA{R: 255, A: 255}},
+		// Под полупрозрачным элементом видна его тень
+		{"полупрозрачный элемент поверх", 180, 70, color.NRGBA{R: 121, B: 128, A: 255}},
+	}
+	for _, tt := range tests {
+		got := color.NRGBAModel.Convert(img.At(tt.x, tt.y)).(color.NRGBA)
+		if !closeColor(got, tt.want) {
+			t.Errorf("%s: пиксель (%d, %d) = %v, ожидалось %v", tt.name, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+// closeColor сравнивает цвета с допуском на округление при смешивании
+func closeColor(a, b color.NRGBA) bool {
+	near := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d >= -2 && d <= 2
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
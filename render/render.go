@@ -0,0 +1,286 @@
+// Package render рисует элементы доски на сервере так же, как их показывает Canvas.vue.
+package render
+
+import (
+	"errors"
+	"io"
+	"math"
+	"micromiro/models"
+	"sort"
+)
+
+var (
+	// ErrFrameNotFound возвращается, если выбранной рамки нет на доске
+	ErrFrameNotFound = errors.New("frame not found")
+	// ErrImageTooLarge возвращается, если картинка при заданном масштабе превышает допустимый размер
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// Оформление по умолчанию, как в Canvas.vue
+const (
+	defaultFontFamily   = "'Segoe UI', Tahoma, Geneva, Verdana, sans-serif"
+	defaultFontSize     = 16.0
+	defaultTextColor    = "#333333"
+	defaultFill         = "#ffffff"
+	defaultStroke       = "#333333"
+	defaultFrameStroke  = "#d0d0d0"
+	defaultCornerRadius = 4.0
+	textPadding         = 10.0
+	framePadding        = 20.0
+	frameTitleSize      = 14.0
+	frameTitleColor     = "#666666"
+	labelSize           = 14.0
+)
+
+// Renderer рисует доски в SVG, PNG и PDF. Безопасен для одновременного использования.
+type Renderer struct {
+	// font - шрифт текста в PNG и PDF, nil - текст рисуется схематично
+	font *Font
+}
+
+// NewRenderer создает отрисовщик. fontPath - путь к шрифту TrueType для текста в PNG и PDF,
+// пустой путь - текст в них рисуется схематичными полосами.
+func NewRenderer(fontPath string) (*Renderer, error) {
+	if fontPath == "" {
+		return &Renderer{}, nil
+	}
+	font, err := LoadFont(fontPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{font: font}, nil
+}
+
+// Export рисует доску в формате выгрузки: models.ExportFormatPNG или models.ExportFormatPDF
+func (r *Renderer) Export(w io.Writer, format string, elements []models.BoardElement, params models.RenderParams) error {
+	if format == models.ExportFormatPDF {
+		return r.PDF(w, elements, params)
+	}
+	return r.PNG(w, elements, params)
+}
+
+// measure возвращает ширину строки текста в PNG и PDF
+func (r *Renderer) measure(text string, size float64) float64 {
+	if r.font == nil {
+		return estimateWidth(text, size)
+	}
+	return r.font.width(text, size)
+}
+
+// rect - прямоугольная область холста
+type rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (r rect) intersects(other rect) bool {
+	return r.X <= other.X+other.Width && other.X <= r.X+r.Width &&
+		r.Y <= other.Y+other.Height && other.Y <= r.Y+r.Height
+}
+
+func (r rect) union(other rect) rect {
+	x, y := math.Min(r.X, other.X), math.Min(r.Y, other.Y)
+	return rect{X: x, Y: y, Width: math.Max(r.X+r.Width, other.X+other.Width) - x, Height: math.Max(r.Y+r.Height, other.Y+other.Height) - y}
+}
+
+// scaleOf возвращает масштаб картинки, по умолчанию 1
+func scaleOf(params models.RenderParams) float64 {
+	if params.Scale <= 0 {
+		return 1
+	}
+	return params.Scale
+}
+
+func indexElements(elements []models.BoardElement) map[int]*models.BoardElement {
+	byID := make(map[int]*models.BoardElement, len(elements))
+	for i := range elements {
+		byID[elements[i].ID] = &elements[i]
+	}
+	return byID
+}
+
+// selectElements выбирает элементы для отрисовки в порядке наложения и видимую область.
+// Без FrameID и Region рисуется вся доска с отступом.
+func selectElements(elements []models.BoardElement, byID map[int]*models.BoardElement, params models.RenderParams) ([]models.BoardElement, rect, error) {
+	visible, view, err := selectArea(elements, byID, params)
+	if err != nil {
+		return nil, rect{}, err
+	}
+	sort.SliceStable(visible, func(i, j int) bool { return visible[i].ZIndex < visible[j].ZIndex })
+	return visible, view, nil
+}
+
+func selectArea(elements []models.BoardElement, byID map[int]*models.BoardElement, params models.RenderParams) ([]models.BoardElement, rect, error) {
+	if params.FrameID != 0 {
+		frame, ok := byID[params.FrameID]
+		if !ok || frame.Type != models.ElementTypeFrame {
+			return nil, rect{}, ErrFrameNotFound
+		}
+		inside := map[int]bool{frame.ID: true}
+		visible := []models.BoardElement{}
+		for _, element := range elements {
+			if insideFrame(element, frame.ID, byID, inside) {
+				visible = append(visible, element)
+			}
+		}
+		return visible, elementBounds(*frame, byID), nil
+	}
+
+	if params.Region != nil {
+		region := rect(*params.Region)
+		visible := []models.BoardElement{}
+		for _, element := range elements {
+			if elementBounds(element, byID).intersects(region) {
+				visible = append(visible, element)
+			}
+		}
+		return visible, region, nil
+	}
+
+	if len(elements) == 0 {
+		return []models.BoardElement{}, rect{Width: 2 * framePadding, Height: 2 * framePadding}, nil
+	}
+	view := elementBounds(elements[0], byID)
+	for _, element := range elements[1:] {
+		view = view.union(elementBounds(element, byID))
+	}
+	// Место под заголовки рамок и тени
+	view = rect{X: view.X - framePadding, Y: view.Y - framePadding, Width: view.Width + 2*framePadding, Height: view.Height + 2*framePadding}
+	return append([]models.BoardElement(nil), elements...), view, nil
+}
+
+// insideFrame сообщает, вложен ли элемент в рамку на любую глубину. Результаты кешируются в inside.
+func insideFrame(element models.BoardElement, frameID int, byID map[int]*models.BoardElement, inside map[int]bool) bool {
+	if result, ok := inside[element.ID]; ok {
+		return result
+	}
+	result := false
+	// Цепочка родителей не длиннее числа элементов, что защищает от циклов в данных
+	for parent, steps := element.ParentID, 0; parent != nil && steps <= len(byID); steps++ {
+		if *parent == frameID {
+			result = true
+			break
+		}
+		next, ok := byID[*parent]
+		if !ok {
+			break
+		}
+		parent = next.ParentID
+	}
+	inside[element.ID] = result
+	return result
+}
+
+// elementBounds возвращает область, которую занимает элемент с учетом поворота
+func elementBounds(element models.BoardElement, byID map[int]*models.BoardElement) rect {
+	if element.Type == models.ElementTypeConnector && element.Connector != nil {
+		points := connectorPoints(element, byID)
+		bounds := rect{X: points[0].X, Y: points[0].Y}
+		for _, p := range points[1:] {
+			bounds = bounds.union(rect{X: p.X, Y: p.Y})
+		}
+		return bounds
+	}
+
+	x, y := float64(element.PositionX), float64(element.PositionY)
+	w, h := float64(element.Width), float64(element.Height)
+	if element.Rotation == 0 {
+		return rect{X: x, Y: y, Width: w, Height: h}
+	}
+	angle := element.Rotation * math.Pi / 180
+	cos, sin := math.Abs(math.Cos(angle)), math.Abs(math.Sin(angle))
+	rw, rh := w*cos+h*sin, w*sin+h*cos
+	return rect{X: x + w/2 - rw/2, Y: y + h/2 - rh/2, Width: rw, Height: rh}
+}
+
+// frames возвращает рамки доски в порядке чтения: сверху вниз, в одном ряду слева направо
+func frames(elements []models.BoardElement) []models.BoardElement {
+	result := []models.BoardElement{}
+	for _, element := range elements {
+		if element.Type == models.ElementTypeFrame {
+			result = append(result, element)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].PositionY != result[j].PositionY {
+			return result[i].PositionY < result[j].PositionY
+		}
+		return result[i].PositionX < result[j].PositionX
+	})
+	return result
+}
+
+// textLayout - строки текста элемента и их положение на холсте
+type textLayout struct {
+	lines []string
+	// x - точка привязки строк по горизонтали
+	x         float64
+	baselines []float64
+	// anchor - выравнивание строк относительно x: start, middle или end
+	anchor string
+	size   float64
+	weight string
+	family string
+}
+
+// layoutText раскладывает содержимое элемента по строкам с переносом по словам, по центру
+// по вертикали и с отступом 10px, как .text-content в Canvas.vue. Не поместившиеся строки
+// обрезаются. Для элемента без текста возвращает nil.
+func layoutText(element models.BoardElement, measure measureFunc) *textLayout {
+	if element.Content == "" {
+		return nil
+	}
+	style := element.Style
+	x, y := float64(element.PositionX), float64(element.PositionY)
+	w, h := float64(element.Width), float64(element.Height)
+	size := floatOr(style.FontSize, defaultFontSize)
+	lineHeight := size * 1.2
+
+	lines := wrapText(element.Content, size, w-2*textPadding, measure)
+	maxLines := int((h - 2*textPadding) / lineHeight)
+	if maxLines < 1 {
+		maxLines = 1
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	layout := &textLayout{lines: lines, x: x + w/2, anchor: "middle", size: size, weight: style.FontWeight, family: stringOr(style.FontFamily, defaultFontFamily)}
+	switch style.TextAlign {
+	case "left", "justify":
+		layout.anchor, layout.x = "start", x+textPadding
+	case "right":
+		layout.anchor, layout.x = "end", x+w-textPadding
+	}
+	top := y + h/2 - lineHeight*float64(len(lines))/2
+	for i := range lines {
+		// Базовая линия смещена от середины строки на 0.35 кегля
+		layout.baselines = append(layout.baselines, top+(float64(i)+0.5)*lineHeight+size*0.35)
+	}
+	return layout
+}
+
+// bold сообщает, что насыщенность шрифта соответствует полужирному
+func bold(weight string) bool {
+	switch weight {
+	case "bold", "bolder", "600", "700", "800", "900":
+		return true
+	}
+	return false
+}
+
+func floatOr(v *float64, fallback float64) float64 {
+	if v == nil {
+		return fallback
+	}
+	return *v
+}
+
+func stringOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
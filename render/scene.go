@@ -0,0 +1,235 @@
+package render
+
+import (
+	"image/color"
+	"math"
+	"micromiro/models"
+	"strings"
+)
+
+// shape - контуры в координатах холста с заливкой и обводкой. PNG и PDF рисуют
+// доску как список таких фигур, текст тоже переводится в контуры.
+type shape struct {
+	contours [][]models.Point
+	// closed - контуры замкнуты и могут быть залиты, иначе это ломаные
+	closed      bool
+	fill        color.NRGBA
+	stroke      color.NRGBA
+	strokeWidth float64
+}
+
+// Сегментов на четверть окружности при замене дуг ломаной
+const arcSegments = 8
+
+// Тень элементов: сдвиг вниз и прозрачность, как box-shadow в Canvas.vue
+const (
+	shadowOffset = 2.0
+	shadowAlpha  = 26
+)
+
+// scene переводит элементы в фигуры в порядке отрисовки
+func (r *Renderer) scene(elements []models.BoardElement, byID map[int]*models.BoardElement) []shape {
+	shapes := []shape{}
+	for _, element := range elements {
+		shapes = append(shapes, r.elementShapes(element, byID)...)
+	}
+	return shapes
+}
+
+func (r *Renderer) elementShapes(element models.BoardElement, byID map[int]*models.BoardElement) []shape {
+	style := element.Style
+	x, y := float64(element.PositionX), float64(element.PositionY)
+	w, h := float64(element.Width), float64(element.Height)
+	textColor := parseColor(defaultTextColor, "")
+
+	var shapes []shape
+	switch element.Type {
+	case models.ElementTypeConnector:
+		shapes = r.connectorShapes(element, byID)
+	case models.ElementTypeGroup:
+		// Группа сама по себе не видна, её элементы рисуются отдельно
+	case models.ElementTypeFrame:
+		shapes = append(shapes, shape{
+			contours:    [][]models.Point{roundedRect(x, y, w, h, floatOr(style.CornerRadius, 0))},
+			closed:      true,
+			fill:        parseColor(style.Fill, defaultFill),
+			stroke:      parseColor(style.Stroke, defaultFrameStroke),
+			strokeWidth: floatOr(style.StrokeWidth, 1),
+		})
+		if element.Content != "" {
+			shapes = append(shapes, r.lineShape(element.Content, x, y-6, frameTitleSize, "start", "", parseColor(frameTitleColor, "")))
+		}
+	case "circle":
+		shapes = append(shapes, bodyShapes(ellipse(models.Point{X: x + w/2, Y: y + h/2}, w/2, h/2), style, defaultFill)...)
+		shapes = append(shapes, r.textShapes(element, textColor)...)
+	case "text":
+		// Текстовый блок без заливки - только текст, как в Canvas.vue без цвета фона
+		if style.Fill != "" {
+			shapes = append(shapes, bodyShapes(roundedRect(x, y, w, h, floatOr(style.CornerRadius, defaultCornerRadius)), style, "")...)
+		}
+		shapes = append(shapes, r.textShapes(element, textColor)...)
+	default:
+		// rectangle и остальные типы рисуются прямоугольником со скругленными углами
+		shapes = append(shapes, bodyShapes(roundedRect(x, y, w, h, floatOr(style.CornerRadius, defaultCornerRadius)), style, defaultFill)...)
+		shapes = append(shapes, r.textShapes(element, textColor)...)
+	}
+
+	// Поворот вокруг центра и общая прозрачность применяются ко всем фигурам элемента
+	rotate := element.Rotation != 0 && element.Type != models.ElementTypeConnector
+	angle := element.Rotation * math.Pi / 180
+	center := models.Point{X: x + w/2, Y: y + h/2}
+	for i := range shapes {
+		if rotate {
+			for _, contour := range shapes[i].contours {
+				for j, p := range contour {
+					contour[j] = rotatePoint(p, center, angle)
+				}
+			}
+		}
+		if style.Opacity != nil {
+			shapes[i].fill = withOpacity(shapes[i].fill, *style.Opacity)
+			shapes[i].stroke = withOpacity(shapes[i].stroke, *style.Opacity)
+		}
+	}
+	return shapes
+}
+
+// bodyShapes возвращает тень и сам контур элемента с заливкой и обводкой из стиля
+func bodyShapes(outline []models.Point, style models.ElementStyle, fill string) []shape {
+	shadow := make([]models.Point, len(outline))
+	for i, p := range outline {
+		shadow[i] = models.Point{X: p.X, Y: p.Y + shadowOffset}
+	}
+	body := shape{contours: [][]models.Point{outline}, closed: true, fill: parseColor(style.Fill, fill)}
+	if style.Stroke != "" || style.StrokeWidth != nil {
+		body.stroke = parseColor(style.Stroke, defaultStroke)
+		body.strokeWidth = floatOr(style.StrokeWidth, 1)
+	}
+	return []shape{
+		{contours: [][]models.Point{shadow}, closed: true, fill: color.NRGBA{A: shadowAlpha}},
+		body,
+	}
+}
+
+func (r *Renderer) textShapes(element models.BoardElement, c color.NRGBA) []shape {
+	layout := layoutText(element, r.measure)
+	if layout == nil {
+		return nil
+	}
+	shapes := make([]shape, 0, len(layout.lines))
+	for i, line := range layout.lines {
+		shapes = append(shapes, r.lineShape(line, layout.x, layout.baselines[i], layout.size, layout.anchor, layout.weight, c))
+	}
+	return shapes
+}
+
+// lineShape переводит строку текста в залитые контуры. Без шрифта слова заменяются
+// полосами их ширины.
+func (r *Renderer) lineShape(line string, x, baseline, size float64, anchor, weight string, c color.NRGBA) shape {
+	switch anchor {
+	case "middle":
+		x -= r.measure(line, size) / 2
+	case "end":
+		x -= r.measure(line, size)
+	}
+
+	if r.font == nil {
+		return shape{contours: greekedLine(line, x, baseline, size), closed: true, fill: withOpacity(c, 0.5)}
+	}
+	s := shape{contours: r.font.outline(line, x, baseline, size), closed: true, fill: c}
+	// Полужирное начертание имитируется обводкой глифов, отдельный файл шрифта не нужен
+	if bold(weight) {
+		s.stroke, s.strokeWidth = c, size/24
+	}
+	return s
+}
+
+// greekedLine возвращает полосы на месте слов строки на высоте строчных букв
+func greekedLine(line string, x, baseline, size float64) [][]models.Point {
+	contours := [][]models.Point{}
+	offset := 0
+	for _, word := range strings.Fields(line) {
+		at := strings.Index(line[offset:], word) + offset
+		left := x + estimateWidth(line[:at], size)
+		width := estimateWidth(word, size) - size*0.1
+		contours = append(contours, roundedRect(left, baseline-size*0.5, width, size*0.4, 0))
+		offset = at + len(word)
+	}
+	return contours
+}
+
+func (r *Renderer) connectorShapes(element models.BoardElement, byID map[int]*models.BoardElement) []shape {
+	if element.Connector == nil {
+		return nil
+	}
+	style := element.Style
+	c := parseColor(style.Stroke, defaultStroke)
+	width := connectorStrokeWidth(style)
+	points := connectorPoints(element, byID)
+
+	shapes := []shape{{contours: [][]models.Point{points}, stroke: c, strokeWidth: width}}
+	n := len(points)
+	for _, head := range []arrowhead{
+		newArrowhead(element.Connector.Source.Arrowhead, points[0], points[1], width),
+		newArrowhead(element.Connector.Target.Arrowhead, points[n-1], points[n-2], width),
+	} {
+		switch {
+		case head.points == nil:
+		case head.filled:
+			shapes = append(shapes, shape{contours: [][]models.Point{head.points}, closed: true, fill: c})
+		default:
+			shapes = append(shapes, shape{contours: [][]models.Point{head.points}, stroke: c, strokeWidth: width})
+		}
+	}
+
+	if label := element.Connector.Label; label != "" {
+		// Подпись посередине линии с белой подложкой по контуру букв
+		mid := midpoint(points)
+		text := r.lineShape(label, mid.X, mid.Y+labelSize*0.35, labelSize, "middle", "", parseColor(defaultTextColor, ""))
+		white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+		halo := shape{contours: text.contours, closed: true, fill: white, stroke: white, strokeWidth: 4}
+		shapes = append(shapes, halo, text)
+	}
+	return shapes
+}
+
+// roundedRect возвращает контур прямоугольника со скругленными углами радиуса radius
+func roundedRect(x, y, w, h, radius float64) []models.Point {
+	radius = math.Min(radius, math.Min(w, h)/2)
+	if radius <= 0 {
+		return []models.Point{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}}
+	}
+	corners := []struct{ cx, cy, from float64 }{
+		{x + w - radius, y + radius, -math.Pi / 2},
+		{x + w - radius, y + h - radius, 0},
+		{x + radius, y + h - radius, math.Pi / 2},
+		{x + radius, y + radius, math.Pi},
+	}
+	points := make([]models.Point, 0, 4*(arcSegments+1))
+	for _, corner := range corners {
+		for i := 0; i <= arcSegments; i++ {
+			a := corner.from + math.Pi/2*float64(i)/arcSegments
+			points = append(points, models.Point{X: corner.cx + radius*math.Cos(a), Y: corner.cy + radius*math.Sin(a)})
+		}
+	}
+	return points
+}
+
+// ellipse возвращает контур эллипса с центром center и полуосями rx, ry
+func ellipse(center models.Point, rx, ry float64) []models.Point {
+	segments := 4 * arcSegments
+	points := make([]models.Point, segments)
+	for i := range points {
+		a := 2 * math.Pi * float64(i) / float64(segments)
+		points[i] = models.Point{X: center.X + rx*math.Cos(a), Y: center.Y + ry*math.Sin(a)}
+	}
+	return points
+}
+
+func rotatePoint(p, center models.Point, angle float64) models.Point {
+	dx, dy := p.X-center.X, p.Y-center.Y
+	return models.Point{
+		X: center.X + dx*math.Cos(angle) - dy*math.Sin(angle),
+		Y: center.Y + dx*math.Sin(angle) + dy*math.Cos(angle),
+	}
+}
@@ -0,0 +1,134 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"micromiro/models"
+	"strconv"
+	"strings"
+)
+
+// SVG рисует элементы доски в порядке наложения и пишет SVG-документ в w.
+// Размеры документа умножаются на масштаб, координаты остаются координатами холста.
+func (r *Renderer) SVG(w io.Writer, elements []models.BoardElement, params models.RenderParams) error {
+	byID := indexElements(elements)
+	visible, view, err := selectElements(elements, byID, params)
+	if err != nil {
+		return err
+	}
+	scale := scaleOf(params)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%s %s %s %s" width="%s" height="%s">`,
+		num(view.X), num(view.Y), num(view.Width), num(view.Height), num(view.Width*scale), num(view.Height*scale))
+	out.WriteString(`<defs><filter id="shadow" x="-20%" y="-20%" width="140%" height="140%">` +
+		`<feDropShadow dx="0" dy="2" stdDeviation="2.5" flood-color="#000000" flood-opacity="0.1"/></filter>` +
+		`<clipPath id="view"><rect x="` + num(view.X) + `" y="` + num(view.Y) + `" width="` + num(view.Width) + `" height="` + num(view.Height) + `"/></clipPath></defs>`)
+	if params.Background != "" {
+		fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`, num(view.X), num(view.Y), num(view.Width), num(view.Height), html.EscapeString(params.Background))
+	}
+	out.WriteString(`<g clip-path="url(#view)">`)
+	for _, element := range visible {
+		writeElement(out, element, byID)
+	}
+	out.WriteString(`</g></svg>`)
+	return out.Flush()
+}
+
+func writeElement(out *bufio.Writer, element models.BoardElement, byID map[int]*models.BoardElement) {
+	style := element.Style
+	x, y := float64(element.PositionX), float64(element.PositionY)
+	w, h := float64(element.Width), float64(element.Height)
+
+	out.WriteString(`<g`)
+	if element.Rotation != 0 && element.Type != models.ElementTypeConnector {
+		fmt.Fprintf(out, ` transform="rotate(%s %s %s)"`, num(element.Rotation), num(x+w/2), num(y+h/2))
+	}
+	if style.Opacity != nil {
+		fmt.Fprintf(out, ` opacity="%s"`, num(*style.Opacity))
+	}
+	out.WriteString(`>`)
+
+	switch element.Type {
+	case models.ElementTypeConnector:
+		writeConnector(out, element, byID)
+	case models.ElementTypeGroup:
+		// Группа сама по себе не видна, её элементы рисуются отдельно
+	case models.ElementTypeFrame:
+		fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" fill="%s" stroke="%s" stroke-width="%s"/>`,
+			num(x), num(y), num(w), num(h), num(floatOr(style.CornerRadius, 0)), colorOr(style.Fill, defaultFill), colorOr(style.Stroke, defaultFrameStroke), num(floatOr(style.StrokeWidth, 1)))
+		if element.Content != "" {
+			fmt.Fprintf(out, `<text x="%s" y="%s" font-family="%s" font-size="%s" fill="%s">%s</text>`,
+				num(x), num(y-6), html.EscapeString(stringOr(style.FontFamily, defaultFontFamily)), num(frameTitleSize), frameTitleColor, html.EscapeString(element.Content))
+		}
+	case "circle":
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" fill="%s"%s filter="url(#shadow)"/>`,
+			num(x+w/2), num(y+h/2), num(w/2), num(h/2), colorOr(style.Fill, defaultFill), strokeAttrs(style))
+		writeText(out, element)
+	case "text":
+		// Текстовый блок без заливки - только текст, как в Canvas.vue без цвета фона
+		if style.Fill != "" {
+			fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" fill="%s"%s filter="url(#shadow)"/>`,
+				num(x), num(y), num(w), num(h), num(floatOr(style.CornerRadius, defaultCornerRadius)), style.Fill, strokeAttrs(style))
+		}
+		writeText(out, element)
+	default:
+		// rectangle и остальные типы рисуются прямоугольником со скругленными углами
+		fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" fill="%s"%s filter="url(#shadow)"/>`,
+			num(x), num(y), num(w), num(h), num(floatOr(style.CornerRadius, defaultCornerRadius)), colorOr(style.Fill, defaultFill), strokeAttrs(style))
+		writeText(out, element)
+	}
+	out.WriteString(`</g>`)
+}
+
+// writeText пишет содержимое элемента. Ширина строк оценивается приблизительно,
+// точную раскладку делает браузер своим шрифтом.
+func writeText(out *bufio.Writer, element models.BoardElement) {
+	layout := layoutText(element, estimateWidth)
+	if layout == nil {
+		return
+	}
+
+	fmt.Fprintf(out, `<text font-family="%s" font-size="%s" fill="%s" text-anchor="%s"`,
+		html.EscapeString(layout.family), num(layout.size), defaultTextColor, layout.anchor)
+	if layout.weight != "" {
+		fmt.Fprintf(out, ` font-weight="%s"`, layout.weight)
+	}
+	out.WriteString(`>`)
+	for i, line := range layout.lines {
+		fmt.Fprintf(out, `<tspan x="%s" y="%s">%s</tspan>`, num(layout.x), num(layout.baselines[i]), html.EscapeString(line))
+	}
+	out.WriteString(`</text>`)
+}
+
+func strokeAttrs(style models.ElementStyle) string {
+	if style.Stroke == "" && style.StrokeWidth == nil {
+		return ""
+	}
+	return fmt.Sprintf(` stroke="%s" stroke-width="%s"`, colorOr(style.Stroke, defaultStroke), num(floatOr(style.StrokeWidth, 1)))
+}
+
+// svgPoints печатает точки для атрибута points
+func svgPoints(points []models.Point) string {
+	coords := make([]string, 0, len(points))
+	for _, p := range points {
+		coords = append(coords, num(p.X)+","+num(p.Y))
+	}
+	return strings.Join(coords, " ")
+}
+
+// num печатает число с точностью до сотых без лишних нулей
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// colorOr возвращает цвет элемента. Цвета стиля проверены при сохранении и не требуют экранирования.
+func colorOr(color, fallback string) string {
+	if color == "" {
+		return fallback
+	}
+	return color
+}
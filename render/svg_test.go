@@ -0,0 +1,143 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"micromiro/models"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	const defs = `<defs><filter id="shadow" x="-20%" y="-20%" width="140%" height="140%">` +
+		`<feDropShadow dx="0" dy="2" stdDeviation="2.5" flood-color="#000000" flood-opacity="0.1"/></filter>`
+	const family = `&#39;Segoe UI&#39;, Tahoma, Geneva, Verdana, sans-serif`
+
+	board := []models.BoardElement{
+		{ID: 1, Type: models.ElementTypeFrame, Content: "A&B", Width: 200, Height: 100},
+		{ID: 2, Type: "circle", Content: "<x>", PositionX: 10, PositionY: 10, Width: 60, Height: 20, ZIndex: 1, ParentID: ref(1)},
+		{ID: 3, Type: "rectangle", PositionX: 300, PositionY: 0, Width: 10, Height: 10, ZIndex: 2},
+	}
+
+	tests := []struct {
+		name     string
+		elements []models.BoardElement
+		params   models.RenderParams
+		want     string
+	}{
+		{
+			name:   "пустая доска",
+			params: models.RenderParams{Background: "#fff"},
+			want: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40" width="40" height="40">` + defs +
+				`<clipPath id="view"><rect x="0" y="0" width="40" height="40"/></clipPath></defs>` +
+				`<rect x="0" y="0" width="40" height="40" fill="#fff"/>` +
+				`<g clip-path="url(#view)"></g></svg>`,
+		},
+		{
+			name:     "прямоугольник с отступом вокруг доски",
+			elements: []models.BoardElement{{ID: 1, Type: "rectangle", Width: 100, Height: 50}},
+			want: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="-20 -20 140 90" width="140" height="90">` + defs +
+				`<clipPath id="view"><rect x="-20" y="-20" width="140" height="90"/></clipPath></defs>` +
+				`<g clip-path="url(#view)"><g><rect x="0" y="0" width="100" height="50" rx="4" fill="#ffffff" filter="url(#shadow)"/></g></g></svg>`,
+		},
+		{
+			name:     "рамка в масштабе 2",
+			elements: board,
+			params:   models.RenderParams{FrameID: 1, Scale: 2},
+			want: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 100" width="400" height="200">` + defs +
+				`<clipPath id="view"><rect x="0" y="0" width="200" height="100"/></clipPath></defs>` +
+				`<g clip-path="url(#view)">` +
+				`<g><rect x="0" y="0" width="200" height="100" rx="0" fill="#ffffff" stroke="#d0d0d0" stroke-width="1"/>` +
+				`<text x="0" y="-6" font-family="` + family + `" font-size="14" fill="#666666">A&amp;B</text></g>` +
+				`<g><ellipse cx="40" cy="20" rx="30" ry="10" fill="#ffffff" filter="url(#shadow)"/>` +
+				`<text font-family="` + family + `" font-size="16" fill="#333333" text-anchor="middle">` +
+				`<tspan x="40" y="25.6">&lt;x&gt;</tspan></text></g>` +
+				`</g></svg>`,
+		},
+		{
+			name: "повернутый прямоугольник и прозрачность",
+			elements: []models.BoardElement{{ID: 1, Type: "rectangle", Width: 10, Height: 10, Rotation: 90,
+				Style: models.ElementStyle{Fill: "#ff0000", Stroke: "#000", Opacity: floatPtr(0.5)}}},
+			params: models.RenderParams{Region: &models.RenderRegion{Width: 10, Height: 10}},
+			want: `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10" width="10" height="10">` + defs +
+				`<clipPath id="view"><rect x="0" y="0" width="10" height="10"/></clipPath></defs>` +
+				`<g clip-path="url(#view)"><g transform="rotate(90 5 5)" opacity="0.5">` +
+				`<rect x="0" y="0" width="10" height="10" rx="4" fill="#ff0000" stroke="#000" stroke-width="1" filter="url(#shadow)"/></g></g></svg>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := (&Renderer{}).SVG(&out, tt.elements, tt.params); err != nil {
+				t.Fatalf("SVG: %v", err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("SVG:\n%s\nожидалось:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectElements(t *testing.T) {
+	elements := []models.BoardElement{
+		{ID: 1, Type: models.ElementTypeFrame, Width: 100, Height: 100, ZIndex: 5},
+		{ID: 2, Type: models.ElementTypeGroup, PositionX: 10, PositionY: 10, Width: 50, Height: 50, ZIndex: 1, ParentID: ref(1)},
+		{ID: 3, Type: "rectangle", PositionX: 20, PositionY: 20, Width: 10, Height: 10, ZIndex: 3, ParentID: ref(2)},
+		{ID: 4, Type: "rectangle", PositionX: 500, PositionY: 500, Width: 10, Height: 10, ZIndex: 2},
+		// Цикл в данных не должен зациклить отбор
+		{ID: 5, Type: models.ElementTypeGroup, ZIndex: 4, ParentID: ref(6)},
+		{ID: 6, Type: models.ElementTypeGroup, ZIndex: 6, ParentID: ref(5)},
+	}
+	byID := indexElements(elements)
+
+	tests := []struct {
+		name    string
+		params  models.RenderParams
+		wantIDs []int
+		view    rect
+		err     error
+	}{
+		{"вся доска", models.RenderParams{}, []int{2, 4, 3, 5, 1, 6}, rect{X: -20, Y: -20, Width: 550, Height: 550}, nil},
+		{"рамка с вложенными на любую глубину", models.RenderParams{FrameID: 1}, []int{2, 3, 1}, rect{Width: 100, Height: 100}, nil},
+		{"область", models.RenderParams{Region: &models.RenderRegion{X: 490, Y: 490, Width: 15, Height: 15}}, []int{4}, rect{X: 490, Y: 490, Width: 15, Height: 15}, nil},
+		{"рамка важнее области", models.RenderParams{FrameID: 1, Region: &models.RenderRegion{X: 490, Y: 490}}, []int{2, 3, 1}, rect{Width: 100, Height: 100}, nil},
+		{"не рамка", models.RenderParams{FrameID: 2}, nil, rect{}, ErrFrameNotFound},
+		{"нет рамки", models.RenderParams{FrameID: 9}, nil, rect{}, ErrFrameNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible, view, err := selectElements(elements, byID, tt.params)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалось %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			ids := []int{}
+			for _, element := range visible {
+				ids = append(ids, element.ID)
+			}
+			if !equalInts(ids, tt.wantIDs) {
+				t.Errorf("элементы %v, ожидалось %v", ids, tt.wantIDs)
+			}
+			if view != tt.view {
+				t.Errorf("область %+v, ожидалось %+v", view, tt.view)
+			}
+		})
+	}
+}
+
+func ref(id int) *int { return &id }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
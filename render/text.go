@@ -0,0 +1,59 @@
+package render
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Средняя ширина символа относительно кегля. Используется, когда метрики шрифта
+// недоступны, поэтому перенос строк в этом случае приблизительный.
+const charWidth = 0.55
+
+// measureFunc возвращает ширину строки текста при заданном кегле
+type measureFunc func(text string, size float64) float64
+
+// estimateWidth оценивает ширину строки по числу символов
+func estimateWidth(text string, size float64) float64 {
+	return float64(utf8.RuneCountInString(text)) * size * charWidth
+}
+
+// wrapText разбивает текст на строки не шире width по словам. Явные переводы строк
+// сохраняются, слишком длинные слова переносятся посимвольно.
+func wrapText(text string, size, width float64, measure measureFunc) []string {
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for measure(word, size) > width && utf8.RuneCountInString(word) > 1 {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				head, tail := splitWord(word, size, width, measure)
+				lines = append(lines, head)
+				word = tail
+			}
+			switch {
+			case line == "":
+				line = word
+			case measure(line+" "+word, size) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// splitWord отделяет от слова самое длинное начало, помещающееся в width, но не короче одного символа
+func splitWord(word string, size, width float64, measure measureFunc) (string, string) {
+	runes := []rune(word)
+	n := 1
+	for n < len(runes) && measure(string(runes[:n+1]), size) <= width {
+		n++
+	}
+	return string(runes[:n]), string(runes[n:])
+}
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"math"
+	"micromiro/models"
+	"reflect"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	// При кегле 10 символ оценивается в 5.5, в ширину 30 помещается 5 символов
+	tests := []struct {
+		name  string
+		text  string
+		width float64
+		want  []string
+	}{
+		{"помещается в строку", "aa bb", 30, []string{"aa bb"}},
+		{"перенос по словам", "aa bb cc", 30, []string{"aa bb", "cc"}},
+		{"лишние пробелы", "  aa   bb  ", 30, []string{"aa bb"}},
+		{"явные переводы строк", "a\n\nb", 30, []string{"a", "", "b"}},
+		{"длинное слово", "abcdefghij", 30, []string{"abcde", "fghij"}},
+		{"длинное слово после короткого", "ab abcdefg", 30, []string{"ab", "abcde", "fg"}},
+		{"кириллица считается по символам", "абвгдеж", 30, []string{"абвгд", "еж"}},
+		{"символ шире строки", "ab", 1, []string{"a", "b"}},
+		{"пустой текст", "", 30, []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapText(tt.text, 10, tt.width, estimateWidth); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapText(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutText(t *testing.T) {
+	element := models.BoardElement{Content: "aa bb cc", PositionX: 0, PositionY: 0, Width: 50, Height: 100}
+
+	layout := layoutText(element, estimateWidth)
+	if layout == nil {
+		t.Fatal("layoutText вернул nil для элемента с текстом")
+	}
+	// Ширина строки 50 - 2*10 = 30 при кегле 16 вмещает 3 символа
+	if want := []string{"aa", "bb", "cc"}; !reflect.DeepEqual(layout.lines, want) {
+		t.Errorf("строки %q, ожидалось %q", layout.lines, want)
+	}
+	if layout.anchor != "middle" || layout.x != 25 {
+		t.Errorf("выравнивание %s от %v, ожидалось middle от 25", layout.anchor, layout.x)
+	}
+	// Три строки по 19.2 центрируются по высоте: первая начинается с 50 - 28.8
+	if got, want := layout.baselines[0], 50-28.8+9.6+16*0.35; math.Abs(got-want) > 1e-9 {
+		t.Errorf("базовая линия первой строки %v, ожидалось %v", got, want)
+	}
+
+	element.Height = 30
+	if layout := layoutText(element, estimateWidth); len(layout.lines) != 1 {
+		t.Errorf("в низкий элемент попало %d строк, ожидалась одна", len(layout.lines))
+	}
+
+	element.Style.TextAlign = "right"
+	if layout := layoutText(element, estimateWidth); layout.anchor != "end" || layout.x != 40 {
+		t.Errorf("выравнивание вправо: %s от %v, ожидалось end от 40", layout.anchor, layout.x)
+	}
+
+	if layoutText(models.BoardElement{Width: 50, Height: 50}, estimateWidth) != nil {
+		t.Error("layoutText вернул строки для элемента без текста")
+	}
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"micromiro/models"
+)
+
+// Размер превью доски в пикселях
+const (
+	ThumbnailWidth  = 320
+	ThumbnailHeight = 200
+)
+
+// Оформление превью и заглушки пустой доски, в цветах карточек BoardsView.vue
+const (
+	thumbnailBackground   = "#ffffff"
+	placeholderBackground = "#f5f7fa"
+	placeholderCard       = "#ffffff"
+	placeholderLine       = "#dde2e8"
+)
+
+// Thumbnail рисует превью доски для списка досок: вся доска целиком по центру картинки
+// ThumbnailWidth x ThumbnailHeight. Мелкие доски не увеличиваются. Для доски без элементов
+// рисуется заглушка пустой доски.
+func (r *Renderer) Thumbnail(w io.Writer, elements []models.BoardElement) error {
+	img := image.NewRGBA(image.Rect(0, 0, ThumbnailWidth, ThumbnailHeight))
+	if len(elements) == 0 {
+		paintPlaceholder(img)
+		return png.Encode(w, img)
+	}
+
+	byID := indexElements(elements)
+	visible, view, err := selectElements(elements, byID, models.RenderParams{})
+	if err != nil {
+		return err
+	}
+	scale := math.Min(1, math.Min(ThumbnailWidth/view.Width, ThumbnailHeight/view.Height))
+	// Область холста расширяется до пропорций картинки, доска остается в середине
+	width, height := ThumbnailWidth/scale, ThumbnailHeight/scale
+	view = rect{X: view.X - (width-view.Width)/2, Y: view.Y - (height-view.Height)/2, Width: width, Height: height}
+
+	r.paint(img, visible, byID, view, scale, thumbnailBackground)
+	return png.Encode(w, img)
+}
+
+// paintPlaceholder рисует заглушку пустой доски: карточку со схематичными строками текста
+func paintPlaceholder(img *image.RGBA) {
+	draw.Draw(img, img.Bounds(), image.NewUniform(parseColor(placeholderBackground, defaultFill)), image.Point{}, draw.Src)
+
+	ras := newRasterizer(img, rect{Width: ThumbnailWidth, Height: ThumbnailHeight}, 1)
+	const cardWidth, cardHeight = 120.0, 76.0
+	x, y := (ThumbnailWidth-cardWidth)/2, (ThumbnailHeight-cardHeight)/2
+	card := [][]models.Point{roundedRect(x, y, cardWidth, cardHeight, 8)}
+	ras.fill(card, parseColor(placeholderCard, defaultFill))
+	ras.fill(strokeOutline(card, true, 1.5, 1), parseColor(placeholderLine, defaultFrameStroke))
+	for i, width := range []float64{72, 56, 36} {
+		line := roundedRect(x+16, y+18+float64(i)*16, width, 8, 4)
+		ras.fill([][]models.Point{line}, parseColor(placeholderLine, defaultFrameStroke))
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"micromiro/models"
+)
+
+// Виды операций пакетного изменения элементов
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// ElementOp - одна операция пакета
+type ElementOp struct {
+	Kind string
+	// Element - создаваемый элемент, для OpCreate
+	Element *models.BoardElement
+	// ElementID - обновляемый или удаляемый элемент, для OpUpdate и OpDelete
+	ElementID int
+	// Modify меняет текущее состояние элемента при OpUpdate, как в ModifyElement
+	Modify func(element *models.BoardElement) error
+	// IfMatch - ожидаемая версия элемента для OpUpdate и OpDelete, 0 - любая
+	IfMatch int64
+	// Delete - что делать со связанными элементами при OpDelete
+	Delete DeleteElementOptions
+}
+
+// ElementOpResult - результат примененной операции пакета
+type ElementOpResult struct {
+	// Element - сохраненный элемент при OpUpdate
+	Element *models.BoardElement
+	// Moved - вложенные элементы, сдвинутые вместе с рамкой или группой при OpUpdate
+	Moved []models.BoardElement
+	// Deletion - элементы, затронутые OpDelete
+	Deletion *ElementDeletion
+}
+
+// BatchError - ошибка операции пакета. Пакет в этом случае не применяется целиком.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyElementBatch применяет операции по порядку в одной транзакции: либо все, либо ни одной.
+// Все измененные элементы получают одну новую версию доски. Создаваемым элементам заполняются ID. Ошибка операции возвращается как *BatchError.
+// Заблокированные элементы меняются и удаляются, а блокировка меняется, только если allowLocked.
+// Пакет записывается в журнал отмены пользователя userID одной операцией.
+func (s *boardStore) ApplyElementBatch(ctx context.Context, boardID, userID int, ops []ElementOp, allowLocked bool) ([]ElementOpResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := newOperationRecorder(userID)
+	results := make([]ElementOpResult, len(ops))
+	for i, op := range ops {
+		switch op.Kind {
+		case OpCreate:
+			op.Element.BoardID, op.Element.Version = boardID, version
+			if err = createElement(ctx, tx, op.Element); err == nil {
+				recorder.created(op.Element.ID)
+			}
+		case OpUpdate:
+			opts := UpdateElementOptions{AllowLocked: allowLocked, IfMatch: op.IfMatch}
+			results[i].Element, results[i].Moved, err = modifyElement(ctx, tx, recorder, boardID, op.ElementID, version, opts, op.Modify)
+		case OpDelete:
+			op.Delete.AllowLocked = allowLocked
+			op.Delete.IfMatch = op.IfMatch
+			op.Delete.UserID = userID
+			if err = recorder.captureSubtree(ctx, tx, boardID, op.ElementID); err == nil {
+				results[i].Deletion, err = deleteElement(ctx, tx, boardID, op.ElementID, op.Delete, version)
+			}
+		default:
+			err = fmt.Errorf("unknown operation %q", op.Kind)
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+	}
+	if err := recorder.save(ctx, tx, boardID, models.OperationBatch, version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+)
+
+const boardColumns = `id, title, description, creator_id, is_public, version, created_at, updated_at, deleted_at`
+
+type boardStore struct {
+	db *sql.DB
+}
+
+// NewBoardStore создает хранилище досок поверх общего пула соединений
+func NewBoardStore(db *sql.DB) BoardStore {
+	return &boardStore{db: db}
+}
+
+// scanner - общий интерфейс *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBoard(row scanner) (*models.Board, error) {
+	var board models.Board
+	err := row.Scan(&board.ID, &board.Title, &board.Description, &board.CreatorID, &board.IsPublic, &board.Version, &board.CreatedAt, &board.UpdatedAt, &board.DeletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+func (s *boardStore) queryBoards(ctx context.Context, query string, args ...interface{}) ([]models.Board, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	boards := []models.Board{}
+	for rows.Next() {
+		board, err := scanBoard(rows)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, *board)
+	}
+	return boards, rows.Err()
+}
+
+func (s *boardStore) CreateBoard(ctx context.Context, board *models.Board, publicSlug string) error {
+	return insertBoard(ctx, s.db, board, publicSlug)
+}
+
+func insertBoard(ctx context.Context, q querier, board *models.Board, publicSlug string) error {
+	query := `INSERT INTO boards (title, description, creator_id, is_public, public_slug, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, version`
+	return q.QueryRowContext(ctx, query, board.Title, board.Description, board.CreatorID, board.IsPublic, publicSlug, board.CreatedAt, board.UpdatedAt).Scan(&board.ID, &board.Version)
+}
+
+// ListBoards возвращает доски, созданные пользователем, и доски, к которым ему выдан доступ.
+// Доски в корзине не возвращаются.
+func (s *boardStore) ListBoards(ctx context.Context, userID int) ([]models.Board, error) {
+	boards, err := s.queryBoards(ctx, `SELECT `+boardColumns+` FROM boards WHERE creator_id = $1 AND deleted_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := s.queryBoards(ctx, `SELECT b.id, b.title, b.description, b.creator_id, b.is_public, b.version, b.created_at, b.updated_at, b.deleted_at
+             FROM boards b
+             JOIN board_permissions bp ON b.id = bp.board_id
+             WHERE bp.user_id = $1 AND b.creator_id != $1 AND b.deleted_at IS NULL`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(boards, shared...), nil
+}
+
+func (s *boardStore) GetBoard(ctx context.Context, boardID int) (*models.Board, error) {
+	return scanBoard(s.db.QueryRowContext(ctx, `SELECT `+boardColumns+` FROM boards WHERE id = $1 AND deleted_at IS NULL`, boardID))
+}
+
+// GetAccess вычисляет права пользователя на доску одним запросом.
+// Доска в корзине недоступна никому и возвращает ErrNotFound.
+func (s *boardStore) GetAccess(ctx context.Context, boardID, userID int) (Access, error) {
+	var access Access
+	var isPublic bool
+	var canEdit sql.NullBool
+	query := `SELECT b.creator_id, b.is_public, bp.can_edit
+              FROM boards b
+              LEFT JOIN board_permissions bp ON bp.board_id = b.id AND bp.user_id = $2
+              WHERE b.id = $1 AND b.deleted_at IS NULL`
+	err := s.db.QueryRowContext(ctx, query, boardID, userID).Scan(&access.CreatorID, &isPublic, &canEdit)
+	if err == sql.ErrNoRows {
+		return access, ErrNotFound
+	}
+	if err != nil {
+		return access, err
+	}
+
+	access.IsCreator = access.CreatorID == userID
+	access.CanEdit = access.IsCreator || (canEdit.Valid && canEdit.Bool)
+	access.CanView = access.IsCreator || isPublic || canEdit.Valid
+	return access, nil
+}
+
+// UpdateBoard меняет свойства доски и возвращает её новую версию.
+// Если ifMatch не 0, доска меняется только в этой версии, иначе возвращается ErrVersionMismatch.
+func (s *boardStore) UpdateBoard(ctx context.Context, boardID int, req models.UpdateBoardRequest, ifMatch int64) (int64, error) {
+	var version int64
+	query := `UPDATE boards SET title = $1, description = $2, is_public = $3, updated_at = $4, version = version + 1
+              WHERE id = $5 AND deleted_at IS NULL AND ($6 = 0 OR version = $6)
+              RETURNING version`
+	err := s.db.QueryRowContext(ctx, query, req.Title, req.Description, req.IsPublic, time.Now(), boardID, ifMatch).Scan(&version)
+	if err == sql.ErrNoRows {
+		if _, err := s.GetBoard(ctx, boardID); err != nil {
+			return 0, err
+		}
+		return 0, ErrVersionMismatch
+	}
+	return version, err
+}
+
+// DeleteBoard переносит доску в корзину. Элементы, разрешения и приглашения сохраняются
+// до окончательного удаления, см. PurgeBoard.
+// Если ifMatch не 0, доска удаляется только в этой версии, иначе возвращается ErrVersionMismatch.
+func (s *boardStore) DeleteBoard(ctx context.Context, boardID int, ifMatch int64) error {
+	var version int64
+	query := `UPDATE boards SET deleted_at = $1, version = version + 1
+              WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)
+              RETURNING version`
+	err := s.db.QueryRowContext(ctx, query, time.Now(), boardID, ifMatch).Scan(&version)
+	if err == sql.ErrNoRows {
+		if _, err := s.GetBoard(ctx, boardID); err != nil {
+			return err
+		}
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+// GetPublicBoard возвращает доску по публичной ссылке, только если она публична
+func (s *boardStore) GetPublicBoard(ctx context.Context, slug string) (*models.Board, error) {
+	query := `SELECT ` + boardColumns + ` FROM boards WHERE public_slug = $1 AND is_public = true AND deleted_at IS NULL`
+	return scanBoard(s.db.QueryRowContext(ctx, query, slug))
+}
+
+func (s *boardStore) GetPublicLink(ctx context.Context, boardID int) (*models.PublicLink, error) {
+	link := models.PublicLink{BoardID: boardID}
+	err := s.db.QueryRowContext(ctx, `SELECT public_slug, is_public FROM boards WHERE id = $1 AND deleted_at IS NULL`, boardID).Scan(&link.Slug, &link.IsPublic)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (s *boardStore) SetPublicSlug(ctx context.Context, boardID int, slug string) (*models.PublicLink, error) {
+	link := models.PublicLink{BoardID: boardID, Slug: &slug}
+	query := `UPDATE boards SET public_slug = $1, updated_at = $2, version = version + 1 WHERE id = $3 AND deleted_at IS NULL RETURNING is_public`
+	err := s.db.QueryRowContext(ctx, query, slug, time.Now(), boardID).Scan(&link.IsPublic)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// EnsurePublicSlug задает доске ссылку slug, только если ссылки у неё ещё нет.
+// Доски, созданные до появления публичных ссылок, получают ссылку при первом запросе.
+// Из параллельных запросов сохраняется ссылка первого, её и возвращают все.
+func (s *boardStore) EnsurePublicSlug(ctx context.Context, boardID int, slug string) (*models.PublicLink, error) {
+	link := models.PublicLink{BoardID: boardID}
+	query := `UPDATE boards SET public_slug = COALESCE(public_slug, $1) WHERE id = $2 AND deleted_at IS NULL RETURNING public_slug, is_public`
+	err := s.db.QueryRowContext(ctx, query, slug, boardID).Scan(&link.Slug, &link.IsPublic)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
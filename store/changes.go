@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+)
+
+// ListChanges возвращает элементы, созданные или измененные после версии since,
+// и надгробия элементов, удаленных после неё. Всё читается из одного снимка базы:
+// изменения доски фиксируются строго по порядку версий, поэтому курсор
+// не пропускает изменений. Если since больше текущей версии доски или меньше версии
+// последнего надгробия, удаленного при очистке корзины, возвращается ErrInvalidCursor.
+func (s *boardStore) ListChanges(ctx context.Context, boardID int, since int64) (*models.BoardChanges, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	board, err := scanBoard(tx.QueryRowContext(ctx, `SELECT `+boardColumns+` FROM boards WHERE id = $1 AND deleted_at IS NULL`, boardID))
+	if err != nil {
+		return nil, err
+	}
+	// Надгробия старше pruned_version удалены при очистке корзины, такой курсор пропустил бы удаления
+	var pruned int64
+	if err := tx.QueryRowContext(ctx, `SELECT pruned_version FROM boards WHERE id = $1`, boardID).Scan(&pruned); err != nil {
+		return nil, err
+	}
+	if since > board.Version || since < pruned {
+		return nil, ErrInvalidCursor
+	}
+
+	changes := &models.BoardChanges{Cursor: board.Version, Board: board, Deleted: []models.ElementTombstone{}}
+	changes.Elements, err = queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND version > $2 AND deleted_at IS NULL ORDER BY z_index, id`, boardID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT element_id, version, deleted_at FROM board_element_tombstones
+                                       WHERE board_id = $1 AND version > $2
+                                       ORDER BY version, element_id`, boardID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tombstone models.ElementTombstone
+		if err := rows.Scan(&tombstone.ID, &tombstone.Version, &tombstone.DeletedAt); err != nil {
+			return nil, err
+		}
+		changes.Deleted = append(changes.Deleted, tombstone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
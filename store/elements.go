@@ -0,0 +1,591 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"micromiro/models"
+
+	"github.com/lib/pq"
+)
+
+const elementColumns = `id, board_id, type, content, position_x, position_y, width, height, style, z_index, rotation, locked, source_element_id, target_element_id, connector, parent_id, version, created_at, updated_at`
+
+func scanElement(row scanner) (*models.BoardElement, error) {
+	var element models.BoardElement
+	var sourceID, targetID sql.NullInt64
+	var connector []byte
+	err := row.Scan(&element.ID, &element.BoardID, &element.Type, &element.Content, &element.PositionX, &element.PositionY, &element.Width, &element.Height, &element.Style, &element.ZIndex, &element.Rotation, &element.Locked, &sourceID, &targetID, &connector, &element.ParentID, &element.Version, &element.CreatedAt, &element.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if connector != nil {
+		element.Connector = &models.Connector{}
+		if err := json.Unmarshal(connector, element.Connector); err != nil {
+			return nil, err
+		}
+		// Ссылки на элементы хранятся в отдельных колонках с внешними ключами, они главнее jsonb
+		element.Connector.Source.ElementID = nullableID(sourceID)
+		element.Connector.Target.ElementID = nullableID(targetID)
+	}
+	return &element, nil
+}
+
+// ListElements возвращает элементы доски в порядке наложения, снизу вверх
+func (s *boardStore) ListElements(ctx context.Context, boardID int) ([]models.BoardElement, error) {
+	return queryElements(ctx, s.db, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL ORDER BY z_index, id`, boardID)
+}
+
+// GetElement возвращает элемент доски
+func (s *boardStore) GetElement(ctx context.Context, boardID, elementID int) (*models.BoardElement, error) {
+	return scanElement(s.db.QueryRowContext(ctx, `SELECT `+elementColumns+` FROM board_elements WHERE id = $1 AND board_id = $2 AND deleted_at IS NULL`, elementID, boardID))
+}
+
+// CreateElement добавляет элемент поверх остальных элементов доски.
+// Создание записывается в журнал отмены пользователя userID.
+func (s *boardStore) CreateElement(ctx context.Context, element *models.BoardElement, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if element.Version, err = bumpBoardVersion(ctx, tx, element.BoardID); err != nil {
+		return err
+	}
+	if err := createElement(ctx, tx, element); err != nil {
+		return err
+	}
+	recorder := newOperationRecorder(userID)
+	recorder.created(element.ID)
+	if err := recorder.save(ctx, tx, element.BoardID, models.OperationCreate, element.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteElement переносит элемент доски в корзину.
+// Вложенные элементы удаляются вместе с ним, если opts.CascadeChildren, иначе
+// становятся элементами верхнего уровня. Прикрепленные коннекторы удаляются, а если
+// opts.DetachConnectors, остаются на доске со свободными концами там, где были прикреплены.
+// Заблокированные элементы удаляются, только если opts.AllowLocked, иначе возвращается ErrLocked.
+func (s *boardStore) DeleteElement(ctx context.Context, boardID, elementID int, opts DeleteElementOptions) (*ElementDeletion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка доски не дает прикрепить что-либо к элементу, который сейчас удаляется
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, err
+	}
+	recorder := newOperationRecorder(opts.UserID)
+	if err := recorder.captureSubtree(ctx, tx, boardID, elementID); err != nil {
+		return nil, err
+	}
+	deletion, err := deleteElement(ctx, tx, boardID, elementID, opts, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := recorder.save(ctx, tx, boardID, models.OperationDelete, version); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// ModifyElement загружает элемент под блокировкой доски, передает его в modify
+// и сохраняет результат. Ошибка modify возвращается без изменений.
+// Если сдвинута рамка или группа, вложенные элементы сдвигаются на то же расстояние
+// в той же транзакции; они возвращаются вызывающему.
+// Заблокированный элемент меняется, только если opts.AllowLocked, иначе возвращается ErrLocked.
+// Без opts.AllowLocked нельзя и поменять блокировку: возвращается ErrLockChange.
+func (s *boardStore) ModifyElement(ctx context.Context, boardID, elementID int, opts UpdateElementOptions, modify func(element *models.BoardElement) error) (*models.BoardElement, []models.BoardElement, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, nil, err
+	}
+	recorder := newOperationRecorder(opts.UserID)
+	element, moved, err := modifyElement(ctx, tx, recorder, boardID, elementID, version, opts, modify)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := recorder.save(ctx, tx, boardID, models.OperationUpdate, version); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return element, moved, nil
+}
+
+// Функции ниже работают внутри транзакции, в которой доска уже заблокирована bumpBoardVersion.
+// Измененные элементы получают новую версию доски: element.Version или параметр version.
+
+func createElement(ctx context.Context, tx *sql.Tx, element *models.BoardElement) error {
+	if err := checkConnectorRefs(ctx, tx, element); err != nil {
+		return err
+	}
+	if err := checkParent(ctx, tx, element); err != nil {
+		return err
+	}
+
+	sourceID, targetID := connectorRefs(element.Connector)
+	query := `INSERT INTO board_elements (board_id, type, content, position_x, position_y, width, height, style, rotation, locked, source_element_id, target_element_id, connector, parent_id, version, created_at, updated_at, z_index)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+                      (SELECT COALESCE(MAX(z_index), 0) + 1 FROM board_elements WHERE board_id = $1))
+              RETURNING id, z_index`
+	err := tx.QueryRowContext(ctx, query, element.BoardID, element.Type, element.Content, element.PositionX, element.PositionY, element.Width, element.Height, element.Style, element.Rotation, element.Locked,
+		sourceID, targetID, element.Connector, element.ParentID, element.Version, element.CreatedAt, element.UpdatedAt).Scan(&element.ID, &element.ZIndex)
+	if isForeignKeyViolation(err) {
+		return ErrInvalidReference
+	}
+	return err
+}
+
+func updateElement(ctx context.Context, tx *sql.Tx, element *models.BoardElement, opts UpdateElementOptions) ([]models.BoardElement, error) {
+	var oldX, oldY int
+	var locked bool
+	var version int64
+	err := tx.QueryRowContext(ctx, `SELECT position_x, position_y, locked, version FROM board_elements WHERE id = $1 AND board_id = $2 AND deleted_at IS NULL`, element.ID, element.BoardID).Scan(&oldX, &oldY, &locked, &version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkElementWrite(locked, version, opts.AllowLocked, opts.IfMatch); err != nil {
+		return nil, err
+	}
+	if element.Locked != locked && !opts.AllowLocked {
+		return nil, ErrLockChange
+	}
+
+	if err := checkConnectorRefs(ctx, tx, element); err != nil {
+		return nil, err
+	}
+	if err := checkParent(ctx, tx, element); err != nil {
+		return nil, err
+	}
+	if !models.IsContainer(element.Type) {
+		// Элемент с вложенными элементами нельзя превратить в обычный
+		var hasChildren bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM board_elements WHERE board_id = $1 AND parent_id = $2 AND deleted_at IS NULL)`, element.BoardID, element.ID).Scan(&hasChildren)
+		if err != nil {
+			return nil, err
+		}
+		if hasChildren {
+			return nil, ErrInvalidParent
+		}
+	}
+
+	sourceID, targetID := connectorRefs(element.Connector)
+	query := `UPDATE board_elements SET type = $1, content = $2, position_x = $3, position_y = $4, width = $5, height = $6, style = $7, rotation = $8, locked = $9,
+                     source_element_id = $10, target_element_id = $11, connector = $12, parent_id = $13, version = $14, updated_at = $15
+              WHERE id = $16 AND board_id = $17
+              RETURNING created_at, z_index`
+	err = tx.QueryRowContext(ctx, query, element.Type, element.Content, element.PositionX, element.PositionY, element.Width, element.Height, element.Style, element.Rotation, element.Locked,
+		sourceID, targetID, element.Connector, element.ParentID, element.Version, element.UpdatedAt, element.ID, element.BoardID).Scan(&element.CreatedAt, &element.ZIndex)
+	if isForeignKeyViolation(err) {
+		return nil, ErrInvalidReference
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	moved := []models.BoardElement{}
+	dx, dy := element.PositionX-oldX, element.PositionY-oldY
+	if models.IsContainer(element.Type) && (dx != 0 || dy != 0) {
+		if moved, err = moveDescendants(ctx, tx, element, dx, dy); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// modifyElement загружает элемент, проверяет условия opts, передает элемент в modify
+// и сохраняет результат. Прежнее состояние поддерева попадает в recorder.
+func modifyElement(ctx context.Context, tx *sql.Tx, recorder *operationRecorder, boardID, elementID int, version int64, opts UpdateElementOptions, modify func(element *models.BoardElement) error) (*models.BoardElement, []models.BoardElement, error) {
+	element, err := scanElement(tx.QueryRowContext(ctx, `SELECT `+elementColumns+` FROM board_elements WHERE id = $1 AND board_id = $2 AND deleted_at IS NULL`, elementID, boardID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkElementWrite(element.Locked, element.Version, opts.AllowLocked, opts.IfMatch); err != nil {
+		return nil, nil, err
+	}
+
+	if err := modify(element); err != nil {
+		return nil, nil, err
+	}
+	element.ID, element.BoardID, element.Version = elementID, boardID, version
+
+	if err := recorder.captureSubtree(ctx, tx, boardID, elementID); err != nil {
+		return nil, nil, err
+	}
+	// Условия уже проверены, доска заблокирована до конца транзакции
+	moved, err := updateElement(ctx, tx, element, UpdateElementOptions{AllowLocked: opts.AllowLocked})
+	if err != nil {
+		return nil, nil, err
+	}
+	return element, moved, nil
+}
+
+func deleteElement(ctx context.Context, tx *sql.Tx, boardID, elementID int, opts DeleteElementOptions, version int64) (*ElementDeletion, error) {
+	element, err := scanElement(tx.QueryRowContext(ctx, `SELECT `+elementColumns+` FROM board_elements WHERE id = $1 AND board_id = $2 AND deleted_at IS NULL`, elementID, boardID))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkElementWrite(element.Locked, element.Version, opts.AllowLocked, opts.IfMatch); err != nil {
+		return nil, err
+	}
+
+	deletion := &ElementDeletion{
+		DeletedChildren:    []int{},
+		OrphanedChildren:   []models.BoardElement{},
+		DeletedConnectors:  []int{},
+		DetachedConnectors: []models.BoardElement{},
+	}
+	deleted := []models.BoardElement{*element}
+	if opts.CascadeChildren {
+		children, err := descendants(ctx, tx, element)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if child.Locked && !opts.AllowLocked {
+				return nil, ErrLocked
+			}
+			deletion.DeletedChildren = append(deletion.DeletedChildren, child.ID)
+		}
+		deleted = append(deleted, children...)
+	} else if deletion.OrphanedChildren, err = orphanChildren(ctx, tx, element, version); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(deleted))
+	for _, e := range deleted {
+		ids = append(ids, int64(e.ID))
+	}
+	if opts.DetachConnectors {
+		deletion.DetachedConnectors, err = detachConnectorsFrom(ctx, tx, boardID, deleted, version)
+	} else if deletion.DeletedConnectors, err = attachedConnectors(ctx, tx, boardID, ids); err == nil {
+		for _, id := range deletion.DeletedConnectors {
+			ids = append(ids, int64(id))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := trashElements(ctx, tx, boardID, elementID, ids, opts.UserID, version); err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// ReorderElement перемещает элемент по стеку наложения и перенумеровывает элементы доски
+// в одной транзакции. Возвращает только элементы, у которых изменился z-index.
+// Перестановка записывается в журнал отмены пользователя userID.
+func (s *boardStore) ReorderElement(ctx context.Context, boardID, elementID, userID int, action string, allowLocked bool) ([]models.ElementOrder, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка доски сериализует все изменения порядка её элементов
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, z_index, locked FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL ORDER BY z_index, id`, boardID)
+	if err != nil {
+		return nil, err
+	}
+	var current []models.ElementOrder
+	position := -1
+	locked := false
+	for rows.Next() {
+		var order models.ElementOrder
+		var isLocked bool
+		if err := rows.Scan(&order.ID, &order.ZIndex, &isLocked); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if order.ID == elementID {
+			position = len(current)
+			locked = isLocked
+		}
+		current = append(current, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if position < 0 {
+		return nil, ErrNotFound
+	}
+	if locked && !allowLocked {
+		return nil, ErrLocked
+	}
+
+	target := position
+	switch action {
+	case models.ZOrderForward:
+		if target < len(current)-1 {
+			target++
+		}
+	case models.ZOrderBackward:
+		if target > 0 {
+			target--
+		}
+	case models.ZOrderFront:
+		target = len(current) - 1
+	case models.ZOrderBack:
+		target = 0
+	}
+
+	// Переставляем элемент и назначаем всем элементам z-index подряд, начиная с 1
+	ids := make([]int, 0, len(current))
+	for i, order := range current {
+		if i != position {
+			ids = append(ids, order.ID)
+		}
+	}
+	ids = append(ids[:target], append([]int{elementID}, ids[target:]...)...)
+
+	previous := make(map[int]int, len(current))
+	for _, order := range current {
+		previous[order.ID] = order.ZIndex
+	}
+
+	changed := []models.ElementOrder{}
+	var changedIDs, changedZ []int64
+	for i, id := range ids {
+		if previous[id] != i+1 {
+			changed = append(changed, models.ElementOrder{ID: id, ZIndex: i + 1, Version: version})
+			changedIDs = append(changedIDs, int64(id))
+			changedZ = append(changedZ, int64(i+1))
+		}
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+
+	recorder := newOperationRecorder(userID)
+	if err := recorder.captureIDs(ctx, tx, boardID, changedIDs); err != nil {
+		return nil, err
+	}
+	query := `UPDATE board_elements e SET z_index = v.z_index, version = $4
+              FROM unnest($1::int[], $2::int[]) AS v(id, z_index)
+              WHERE e.id = v.id AND e.board_id = $3`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(changedIDs), pq.Array(changedZ), boardID, version); err != nil {
+		return nil, err
+	}
+	if err := recorder.save(ctx, tx, boardID, models.OperationReorder, version); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// attachedConnectors возвращает ID коннекторов, прикрепленных к удаляемым элементам
+func attachedConnectors(ctx context.Context, tx *sql.Tx, boardID int, ids []int64) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM board_elements
+                                       WHERE board_id = $1 AND NOT (id = ANY($2::int[])) AND deleted_at IS NULL
+                                         AND (source_element_id = ANY($2::int[]) OR target_element_id = ANY($2::int[]))`, boardID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connectors := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		connectors = append(connectors, id)
+	}
+	return connectors, rows.Err()
+}
+
+// detachConnectorsFrom открепляет коннекторы от удаляемых элементов, оставляя их концы
+// в точках привязки, и возвращает измененные коннекторы
+func detachConnectorsFrom(ctx context.Context, tx *sql.Tx, boardID int, deleted []models.BoardElement, version int64) ([]models.BoardElement, error) {
+	byID := make(map[int]models.BoardElement, len(deleted))
+	ids := make([]int64, 0, len(deleted))
+	for _, element := range deleted {
+		byID[element.ID] = element
+		ids = append(ids, int64(element.ID))
+	}
+
+	connectors, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements
+                                               WHERE board_id = $1 AND NOT (id = ANY($2::int[])) AND deleted_at IS NULL
+                                                 AND (source_element_id = ANY($2::int[]) OR target_element_id = ANY($2::int[]))`, boardID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range connectors {
+		connector := connectors[i].Connector
+		for _, end := range []*models.ConnectorEnd{&connector.Source, &connector.Target} {
+			if end.ElementID == nil {
+				continue
+			}
+			if element, ok := byID[*end.ElementID]; ok {
+				point := element.AnchorPoint(end.Anchor)
+				end.ElementID = nil
+				end.X, end.Y = point.X, point.Y
+			}
+		}
+
+		sourceID, targetID := connectorRefs(connector)
+		connectors[i].Version = version
+		err := tx.QueryRowContext(ctx, `UPDATE board_elements SET source_element_id = $1, target_element_id = $2, connector = $3, version = $4, updated_at = NOW()
+                                        WHERE id = $5
+                                        RETURNING updated_at`, sourceID, targetID, connector, version, connectors[i].ID).Scan(&connectors[i].UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return connectors, nil
+}
+
+// checkConnectorRefs проверяет, что концы коннектора прикреплены к существующим
+// элементам той же доски, которые сами не являются коннекторами
+func checkConnectorRefs(ctx context.Context, q querier, element *models.BoardElement) error {
+	if element.Connector == nil {
+		return nil
+	}
+
+	ids := []int64{}
+	for _, id := range []*int{element.Connector.Source.ElementID, element.Connector.Target.ElementID} {
+		if id == nil {
+			continue
+		}
+		if *id == element.ID {
+			return ErrInvalidReference
+		}
+		if len(ids) == 0 || ids[0] != int64(*id) {
+			ids = append(ids, int64(*id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var found int
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM board_elements WHERE board_id = $1 AND id = ANY($2::int[]) AND type <> $3 AND deleted_at IS NULL`,
+		element.BoardID, pq.Array(ids), models.ElementTypeConnector).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != len(ids) {
+		return ErrInvalidReference
+	}
+	return nil
+}
+
+// connectorRefs возвращает значения колонок source_element_id и target_element_id
+func connectorRefs(connector *models.Connector) (source, target *int) {
+	if connector == nil {
+		return nil, nil
+	}
+	return connector.Source.ElementID, connector.Target.ElementID
+}
+
+func nullableID(id sql.NullInt64) *int {
+	if !id.Valid {
+		return nil
+	}
+	value := int(id.Int64)
+	return &value
+}
+
+// isForeignKeyViolation сообщает, что запрос сослался на отсутствующий элемент
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// queryElements выполняет запрос, возвращающий колонки elementColumns
+func queryElements(ctx context.Context, q querier, query string, args ...interface{}) ([]models.BoardElement, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	elements := []models.BoardElement{}
+	for rows.Next() {
+		element, err := scanElement(rows)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, *element)
+	}
+	return elements, rows.Err()
+}
+
+// querier - общий интерфейс *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// bumpBoardVersion увеличивает версию доски и возвращает новую. Строка доски
+// остается заблокированной до конца транзакции, что сериализует изменения элементов.
+// Если транзакция откатится, версия не изменится.
+func bumpBoardVersion(ctx context.Context, tx *sql.Tx, boardID int) (int64, error) {
+	var version int64
+	err := tx.QueryRowContext(ctx, `UPDATE boards SET version = version + 1 WHERE id = $1 AND deleted_at IS NULL RETURNING version`, boardID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return version, err
+}
+
+// checkElementWrite проверяет, можно ли изменить элемент: блокировку и ожидаемую версию
+func checkElementWrite(locked bool, version int64, allowLocked bool, ifMatch int64) error {
+	if locked && !allowLocked {
+		return ErrLocked
+	}
+	if ifMatch != 0 && version != ifMatch {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// expectAffected возвращает ErrNotFound, если запрос не изменил ни одной строки
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"micromiro/models"
+)
+
+// ImportBoard создает доску с элементами из документа экспорта в одной транзакции.
+// ID элементов в документе локальны: элементы получают новые ID, а parent_id и концы
+// коннекторов переписываются на них. Ссылки должны быть проверены заранее, см. BoardExport.Validate.
+// Возвращает соответствие ID из документа новым ID.
+func (s *boardStore) ImportBoard(ctx context.Context, board *models.Board, publicSlug string, elements []models.BoardElement) (map[int]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertBoard(ctx, tx, board, publicSlug); err != nil {
+		return nil, err
+	}
+
+	// Сначала создаем элементы без ссылок, затем, когда известны все новые ID, проставляем ссылки
+	ids := make(map[int]int, len(elements))
+	query := `INSERT INTO board_elements (board_id, type, content, position_x, position_y, width, height, style, z_index, rotation, locked, version, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13)
+              RETURNING id`
+	for _, element := range elements {
+		var id int
+		err := tx.QueryRowContext(ctx, query, board.ID, element.Type, element.Content, element.PositionX, element.PositionY, element.Width, element.Height, element.Style,
+			element.ZIndex, element.Rotation, element.Locked, board.Version, board.CreatedAt).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids[element.ID] = id
+	}
+
+	for _, element := range elements {
+		if element.ParentID == nil && element.Connector == nil {
+			continue
+		}
+		var parentID *int
+		if element.ParentID != nil {
+			parent := ids[*element.ParentID]
+			parentID = &parent
+		}
+		if element.Connector != nil {
+			for _, end := range []*models.ConnectorEnd{&element.Connector.Source, &element.Connector.Target} {
+				if end.ElementID != nil {
+					target := ids[*end.ElementID]
+					end.ElementID = &target
+				}
+			}
+		}
+		sourceID, targetID := connectorRefs(element.Connector)
+		_, err := tx.ExecContext(ctx, `UPDATE board_elements SET parent_id = $1, source_element_id = $2, target_element_id = $3, connector = $4 WHERE id = $5`,
+			parentID, sourceID, targetID, element.Connector, ids[element.ID])
+		if isForeignKeyViolation(err) {
+			return nil, ErrInvalidReference
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
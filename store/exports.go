@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+)
+
+const exportJobColumns = `id, board_id, user_id, format, params, status, error, board_version, COALESCE(octet_length(result), 0), created_at, finished_at`
+
+func scanExportJob(row scanner) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := row.Scan(&job.ID, &job.BoardID, &job.UserID, &job.Format, &job.Params, &job.Status, &job.Error, &job.BoardVersion, &job.Size, &job.CreatedAt, &job.FinishedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CreateExportJob ставит выгрузку доски в очередь фоновой задачи. Если у пользователя уже есть
+// такая же выгрузка в очереди или готовая выгрузка текущей версии доски boardVersion,
+// новая не создается: job заполняется найденной. Если незавершенных выгрузок у пользователя
+// уже maxActive, возвращается ErrLimitExceeded.
+func (s *boardStore) CreateExportJob(ctx context.Context, job *models.ExportJob, boardVersion int64, maxActive int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка пользователя не дает параллельным запросам обойти лимит и поставить дубликаты
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, job.UserID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	existing, err := scanExportJob(tx.QueryRowContext(ctx, `SELECT `+exportJobColumns+` FROM board_export_jobs
+                                                           WHERE user_id = $1 AND board_id = $2 AND format = $3 AND params = $4
+                                                             AND (status IN ($5, $6) OR status = $7 AND board_version = $8)
+                                                           ORDER BY id DESC
+                                                           LIMIT 1`,
+		job.UserID, job.BoardID, job.Format, job.Params, models.ExportJobPending, models.ExportJobRunning, models.ExportJobDone, boardVersion))
+	if err == nil {
+		*job = *existing
+		return nil
+	}
+	if err != ErrNotFound {
+		return err
+	}
+
+	var active int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM board_export_jobs WHERE user_id = $1 AND status IN ($2, $3)`,
+		job.UserID, models.ExportJobPending, models.ExportJobRunning).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active >= maxActive {
+		return ErrLimitExceeded
+	}
+
+	job.Status = models.ExportJobPending
+	err = tx.QueryRowContext(ctx, `INSERT INTO board_export_jobs (board_id, user_id, format, params, status, created_at)
+                                   VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		job.BoardID, job.UserID, job.Format, job.Params, job.Status, job.CreatedAt).Scan(&job.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetExportJob возвращает выгрузку доски без файла
+func (s *boardStore) GetExportJob(ctx context.Context, boardID, jobID int) (*models.ExportJob, error) {
+	return scanExportJob(s.db.QueryRowContext(ctx, `SELECT `+exportJobColumns+` FROM board_export_jobs WHERE board_id = $1 AND id = $2`, boardID, jobID))
+}
+
+// GetExportResult возвращает файл готовой выгрузки. Для незавершенной выгрузки возвращает ErrNotFound.
+func (s *boardStore) GetExportResult(ctx context.Context, boardID, jobID int) ([]byte, error) {
+	var result []byte
+	err := s.db.QueryRowContext(ctx, `SELECT result FROM board_export_jobs WHERE board_id = $1 AND id = $2 AND status = $3`,
+		boardID, jobID, models.ExportJobDone).Scan(&result)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return result, err
+}
+
+// ClaimExportJob берет в работу самую старую ожидающую выгрузку. Выгрузки, начатые раньше
+// staleBefore, считаются брошенными остановленным сервером и берутся повторно, пока их
+// не брали maxAttempts раз; после этого они завершаются со статусом failed.
+// Если брать нечего, возвращает ErrNotFound.
+func (s *boardStore) ClaimExportJob(ctx context.Context, staleBefore time.Time, maxAttempts int) (*models.ExportJob, error) {
+	now := time.Now()
+	_, err := s.db.ExecContext(ctx, `UPDATE board_export_jobs SET status = $1, error = $2, result = NULL, finished_at = $3
+                                     WHERE status = $4 AND started_at < $5 AND attempts >= $6`,
+		models.ExportJobFailed, "Выгрузка прерывалась слишком много раз", now, models.ExportJobRunning, staleBefore, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	// SKIP LOCKED позволяет нескольким экземплярам сервера разбирать очередь одновременно
+	return scanExportJob(s.db.QueryRowContext(ctx, `UPDATE board_export_jobs SET status = $1, started_at = $2, attempts = attempts + 1
+                                                   WHERE id = (
+                                                       SELECT id FROM board_export_jobs
+                                                       WHERE status = $3 OR (status = $1 AND started_at < $4)
+                                                       ORDER BY created_at, id
+                                                       LIMIT 1
+                                                       FOR UPDATE SKIP LOCKED
+                                                   )
+                                                   RETURNING `+exportJobColumns,
+		models.ExportJobRunning, now, models.ExportJobPending, staleBefore))
+}
+
+// FinishExportJob сохраняет готовый файл выгрузки или, если failure не пустой, причину неудачи
+func (s *boardStore) FinishExportJob(ctx context.Context, jobID int, boardVersion int64, result []byte, failure string) error {
+	status := models.ExportJobDone
+	if failure != "" {
+		status, result = models.ExportJobFailed, nil
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE board_export_jobs SET status = $1, error = $2, board_version = $3, result = $4, finished_at = $5
+                                     WHERE id = $6`, status, failure, boardVersion, result, time.Now(), jobID)
+	return err
+}
+
+// PurgeExportJobs удаляет завершенные выгрузки, закончившиеся раньше before
+func (s *boardStore) PurgeExportJobs(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM board_export_jobs WHERE status IN ($1, $2) AND finished_at < $3`,
+		models.ExportJobDone, models.ExportJobFailed, before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+)
+
+// descendantsCTE выбирает ID всех элементов, вложенных в элемент $2 доски $1, кроме элементов в корзине
+const descendantsCTE = `WITH RECURSIVE descendants AS (
+                            SELECT id FROM board_elements WHERE board_id = $1 AND parent_id = $2 AND deleted_at IS NULL
+                            UNION ALL
+                            SELECT e.id FROM board_elements e JOIN descendants d ON e.parent_id = d.id WHERE e.deleted_at IS NULL
+                        )`
+
+// checkParent проверяет, что родитель элемента - рамка или группа той же доски
+// и что новая вложенность не образует цикл
+func checkParent(ctx context.Context, q querier, element *models.BoardElement) error {
+	if element.ParentID == nil {
+		return nil
+	}
+	if *element.ParentID == element.ID {
+		return ErrInvalidParent
+	}
+
+	var parentType string
+	err := q.QueryRowContext(ctx, `SELECT type FROM board_elements WHERE id = $1 AND board_id = $2 AND deleted_at IS NULL`, *element.ParentID, element.BoardID).Scan(&parentType)
+	if err == sql.ErrNoRows {
+		return ErrInvalidParent
+	}
+	if err != nil {
+		return err
+	}
+	if !models.IsContainer(parentType) {
+		return ErrInvalidParent
+	}
+
+	// У нового элемента еще нет вложенных, цикл возможен только при обновлении
+	if element.ID == 0 {
+		return nil
+	}
+	var cycle bool
+	err = q.QueryRowContext(ctx, descendantsCTE+` SELECT EXISTS (SELECT 1 FROM descendants WHERE id = $3)`, element.BoardID, element.ID, *element.ParentID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrInvalidParent
+	}
+	return nil
+}
+
+// descendants возвращает все элементы, вложенные в элемент на любую глубину
+func descendants(ctx context.Context, q querier, element *models.BoardElement) ([]models.BoardElement, error) {
+	return queryElements(ctx, q, descendantsCTE+` SELECT `+elementColumns+` FROM board_elements WHERE id IN (SELECT id FROM descendants)`, element.BoardID, element.ID)
+}
+
+// moveDescendants сдвигает вложенные элементы вслед за рамкой или группой и возвращает их.
+// У вложенных коннекторов сдвигаются свободные концы и промежуточные точки.
+func moveDescendants(ctx context.Context, q querier, element *models.BoardElement, dx, dy int) ([]models.BoardElement, error) {
+	moved, err := queryElements(ctx, q, descendantsCTE+`
+        UPDATE board_elements SET position_x = position_x + $3, position_y = position_y + $4, version = $5, updated_at = $6
+        WHERE id IN (SELECT id FROM descendants)
+        RETURNING `+elementColumns, element.BoardID, element.ID, dx, dy, element.Version, element.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range moved {
+		if moved[i].Connector == nil {
+			continue
+		}
+		moved[i].Connector.Translate(float64(dx), float64(dy))
+		if _, err := q.ExecContext(ctx, `UPDATE board_elements SET connector = $1 WHERE id = $2`, moved[i].Connector, moved[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// orphanChildren переносит непосредственно вложенные элементы на верхний уровень и возвращает их
+func orphanChildren(ctx context.Context, q querier, element *models.BoardElement, version int64) ([]models.BoardElement, error) {
+	return queryElements(ctx, q, `UPDATE board_elements SET parent_id = NULL, version = $3, updated_at = NOW()
+                                   WHERE board_id = $1 AND parent_id = $2 AND deleted_at IS NULL
+                                   RETURNING `+elementColumns, element.BoardID, element.ID, version)
+}
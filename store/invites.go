@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+)
+
+func (s *boardStore) CreateInvite(ctx context.Context, invite *models.BoardInvite) error {
+	query := `INSERT INTO board_invites (board_id, token, role, expires_at, max_uses, created_by, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	return s.db.QueryRowContext(ctx, query, invite.BoardID, invite.Token, invite.Role, invite.ExpiresAt, invite.MaxUses, invite.CreatedBy, invite.CreatedAt).Scan(&invite.ID)
+}
+
+// ListActiveInvites возвращает приглашения, которые еще не истекли и не исчерпаны
+func (s *boardStore) ListActiveInvites(ctx context.Context, boardID int, now time.Time) ([]models.BoardInvite, error) {
+	query := `SELECT id, board_id, token, role, expires_at, max_uses, uses, created_by, created_at
+              FROM board_invites
+              WHERE board_id = $1
+                AND (expires_at IS NULL OR expires_at > $2)
+                AND (max_uses IS NULL OR uses < max_uses)
+              ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, boardID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.BoardInvite{}
+	for rows.Next() {
+		var invite models.BoardInvite
+		if err := rows.Scan(&invite.ID, &invite.BoardID, &invite.Token, &invite.Role, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses, &invite.CreatedBy, &invite.CreatedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (s *boardStore) DeleteInvite(ctx context.Context, boardID, inviteID int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM board_invites WHERE id = $1 AND board_id = $2`, inviteID, boardID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// AcceptInvite выдает пользователю доступ по приглашению в одной транзакции.
+// Существующий доступ не понижается, а использование засчитывается,
+// только если доступ действительно выдан или расширен.
+func (s *boardStore) AcceptInvite(ctx context.Context, token string, userID int, now time.Time) (*InviteAcceptance, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Блокируем приглашение, чтобы параллельные запросы не превысили лимит использований
+	var invite models.BoardInvite
+	var creatorID int
+	query := `SELECT bi.id, bi.board_id, bi.role, bi.expires_at, bi.max_uses, bi.uses, b.creator_id
+              FROM board_invites bi
+              JOIN boards b ON b.id = bi.board_id
+              WHERE bi.token = $1 AND b.deleted_at IS NULL
+              FOR UPDATE OF bi`
+	err = tx.QueryRowContext(ctx, query, token).Scan(&invite.ID, &invite.BoardID, &invite.Role, &invite.ExpiresAt, &invite.MaxUses, &invite.Uses, &creatorID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if invite.ExpiresAt != nil && !invite.ExpiresAt.After(now) {
+		return nil, ErrInviteExpired
+	}
+	if invite.MaxUses != nil && invite.Uses >= *invite.MaxUses {
+		return nil, ErrInviteUsedUp
+	}
+
+	acceptance := &InviteAcceptance{BoardID: invite.BoardID}
+	if creatorID == userID {
+		acceptance.IsCreator = true
+		return acceptance, nil
+	}
+
+	canEdit := invite.Role == models.InviteRoleEditor
+	query = `INSERT INTO board_permissions (board_id, user_id, can_edit, created_at, updated_at)
+             VALUES ($1, $2, $3, $4, $4)
+             ON CONFLICT (board_id, user_id) DO UPDATE
+             SET can_edit = true, updated_at = EXCLUDED.updated_at
+             WHERE EXCLUDED.can_edit AND NOT board_permissions.can_edit`
+	result, err := tx.ExecContext(ctx, query, invite.BoardID, userID, canEdit, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		acceptance.Granted = true
+		if _, err := tx.ExecContext(ctx, `UPDATE board_invites SET uses = uses + 1 WHERE id = $1`, invite.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return acceptance, nil
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"micromiro/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// operationHistoryLimit - сколько последних операций пользователя на доске хранится для отмены
+const operationHistoryLimit = 100
+
+// operationRecorder собирает состояния элементов до изменения внутри транзакции
+// и записывает операцию в журнал отмены. Запоминать можно с запасом: в журнал попадают
+// только элементы, получившие новую версию доски или удаленные в этой транзакции.
+// Для userID 0 журнал не ведется, методы nil-рекордера ничего не делают.
+type operationRecorder struct {
+	userID int
+	before map[int]*models.BoardElement
+	order  []int
+}
+
+func newOperationRecorder(userID int) *operationRecorder {
+	if userID == 0 {
+		return nil
+	}
+	return &operationRecorder{userID: userID, before: map[int]*models.BoardElement{}}
+}
+
+func (r *operationRecorder) remember(id int, element *models.BoardElement) {
+	if _, seen := r.before[id]; seen {
+		return
+	}
+	r.before[id] = element
+	r.order = append(r.order, id)
+}
+
+// created запоминает, что элемента до операции не было
+func (r *operationRecorder) created(id int) {
+	if r == nil {
+		return
+	}
+	r.remember(id, nil)
+}
+
+// captureSubtree запоминает элемент, вложенные в него элементы и прикрепленные к ним коннекторы -
+// всё, что может затронуть изменение или удаление элемента
+func (r *operationRecorder) captureSubtree(ctx context.Context, q querier, boardID, elementID int) error {
+	if r == nil {
+		return nil
+	}
+	elements, err := queryElements(ctx, q, descendantsCTE+`, subtree AS (SELECT $2::int AS id UNION SELECT id FROM descendants)
+        SELECT `+elementColumns+` FROM board_elements
+        WHERE board_id = $1 AND deleted_at IS NULL
+          AND (id IN (SELECT id FROM subtree)
+                                 OR source_element_id IN (SELECT id FROM subtree)
+                                 OR target_element_id IN (SELECT id FROM subtree))`, boardID, elementID)
+	if err != nil {
+		return err
+	}
+	for i := range elements {
+		r.remember(elements[i].ID, &elements[i])
+	}
+	return nil
+}
+
+// captureIDs запоминает элементы с указанными ID
+func (r *operationRecorder) captureIDs(ctx context.Context, q querier, boardID int, ids []int64) error {
+	if r == nil {
+		return nil
+	}
+	elements, err := queryElements(ctx, q, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND id = ANY($2::int[]) AND deleted_at IS NULL`, boardID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	for i := range elements {
+		r.remember(elements[i].ID, &elements[i])
+	}
+	return nil
+}
+
+// save записывает операцию в журнал и очищает стек повтора пользователя на доске:
+// после новой операции отмененные повторить нельзя
+func (r *operationRecorder) save(ctx context.Context, tx *sql.Tx, boardID int, kind string, version int64) error {
+	if r == nil || len(r.order) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(r.order))
+	for _, id := range r.order {
+		ids = append(ids, int64(id))
+	}
+	after, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND id = ANY($2::int[]) AND version = $3 AND deleted_at IS NULL`, boardID, pq.Array(ids), version)
+	if err != nil {
+		return err
+	}
+	afterByID := make(map[int]*models.BoardElement, len(after))
+	for i := range after {
+		afterByID[after[i].ID] = &after[i]
+	}
+	deleted, err := tombstonedAt(ctx, tx, boardID, ids, version)
+	if err != nil {
+		return err
+	}
+
+	changes := []models.ElementChange{}
+	for _, id := range r.order {
+		change := models.ElementChange{ID: id, Before: r.before[id], After: afterByID[id]}
+		// Не изменившиеся элементы и созданные и тут же удаленные в журнал не попадают
+		if change.After == nil && (!deleted[id] || change.Before == nil) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM board_operations WHERE board_id = $1 AND user_id = $2 AND undone_at IS NOT NULL`, boardID, r.userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO board_operations (board_id, user_id, kind, changes, applied_version, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.ExecContext(ctx, query, boardID, r.userID, kind, data, version, time.Now()); err != nil {
+		return err
+	}
+	query = `DELETE FROM board_operations
+             WHERE board_id = $1 AND user_id = $2 AND id <= (
+                 SELECT id FROM board_operations WHERE board_id = $1 AND user_id = $2
+                 ORDER BY id DESC OFFSET $3 LIMIT 1)`
+	_, err = tx.ExecContext(ctx, query, boardID, r.userID, operationHistoryLimit)
+	return err
+}
+
+// UndoOperation отменяет последнюю операцию пользователя на доске, возвращая затронутые
+// элементы в состояние до неё. Операции, элементы которых с тех пор изменил кто-то другой,
+// пропускаются и удаляются из журнала. Собственные отмененные операции пользователя
+// отмене более ранних не мешают. Если отменять нечего, возвращается ErrNoOperation.
+func (s *boardStore) UndoOperation(ctx context.Context, boardID, userID int) (*models.OperationReplay, error) {
+	return s.replayOperation(ctx, boardID, userID, true)
+}
+
+// RedoOperation повторяет последнюю отмененную операцию пользователя на доске
+// по тем же правилам, что и UndoOperation
+func (s *boardStore) RedoOperation(ctx context.Context, boardID, userID int) (*models.OperationReplay, error) {
+	return s.replayOperation(ctx, boardID, userID, false)
+}
+
+func (s *boardStore) replayOperation(ctx context.Context, boardID, userID int, undo bool) (*models.OperationReplay, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, board_id, user_id, kind, changes, applied_version, created_at, undone_at FROM board_operations
+              WHERE board_id = $1 AND user_id = $2 AND undone_at IS NULL
+              ORDER BY id DESC LIMIT 1`
+	if !undo {
+		query = `SELECT id, board_id, user_id, kind, changes, applied_version, created_at, undone_at FROM board_operations
+                 WHERE board_id = $1 AND user_id = $2 AND undone_at IS NOT NULL
+                 ORDER BY undone_at DESC, id DESC LIMIT 1`
+	}
+
+	replay := &models.OperationReplay{Version: version, Created: []models.BoardElement{}, Updated: []models.BoardElement{}, Deleted: []int{}}
+	for {
+		var data []byte
+		var applied int64
+		operation := &replay.Operation
+		err := tx.QueryRowContext(ctx, query, boardID, userID).Scan(&operation.ID, &operation.BoardID, &operation.UserID, &operation.Kind, &data, &applied, &operation.CreatedAt, &operation.UndoneAt)
+		if err == sql.ErrNoRows {
+			// Пропущенные операции удаляются из журнала, даже если применить нечего
+			if replay.Skipped > 0 {
+				if err := tx.Commit(); err != nil {
+					return nil, err
+				}
+			}
+			return nil, ErrNoOperation
+		}
+		if err != nil {
+			return nil, err
+		}
+		var changes []models.ElementChange
+		if err := json.Unmarshal(data, &changes); err != nil {
+			return nil, err
+		}
+
+		// При отмене текущим должно быть состояние после операции, при повторе - до неё
+		current, target := make([]*models.BoardElement, len(changes)), make([]*models.BoardElement, len(changes))
+		for i, change := range changes {
+			current[i], target[i] = change.After, change.Before
+			if !undo {
+				current[i], target[i] = change.Before, change.After
+			}
+		}
+
+		ok, err := operationApplies(ctx, tx, boardID, changes, current, target, applied)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM board_operations WHERE id = $1`, operation.ID); err != nil {
+				return nil, err
+			}
+			replay.Skipped++
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, deferElementRefs); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		removed, restored := []int64{}, []int64{}
+		for i, change := range changes {
+			if target[i] == nil {
+				removed = append(removed, int64(change.ID))
+				replay.Deleted = append(replay.Deleted, change.ID)
+				continue
+			}
+			element := *target[i]
+			element.BoardID, element.Version, element.UpdatedAt = boardID, version, now
+			if err := upsertElement(ctx, tx, &element); err != nil {
+				return nil, err
+			}
+			restored = append(restored, int64(element.ID))
+			if current[i] == nil {
+				replay.Created = append(replay.Created, element)
+			} else {
+				replay.Updated = append(replay.Updated, element)
+			}
+		}
+		// Отмена создания переносит элементы в корзину пользователя, повтор вернет их оттуда
+		if err := trashElements(ctx, tx, boardID, 0, removed, userID, version); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM board_element_tombstones WHERE element_id = ANY($1::int[])`, pq.Array(restored)); err != nil {
+			return nil, err
+		}
+
+		query := `UPDATE board_operations SET undone_at = CASE WHEN $2 THEN $3::timestamp ELSE NULL END, applied_version = $4
+                  WHERE id = $1
+                  RETURNING undone_at`
+		if err := tx.QueryRowContext(ctx, query, operation.ID, undo, now, version).Scan(&operation.UndoneAt); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrInvalidReference
+		}
+		return nil, err
+	}
+	return replay, nil
+}
+
+// operationApplies проверяет, что элементы операции с версии applied никто не менял
+// и что после её применения не останется ссылок на отсутствующие элементы.
+// Элемент с более новой версией подходит, если он в том же состоянии, что и после
+// применения операции: так бывает, когда пользователь отменил собственные более поздние
+// операции над ним.
+func operationApplies(ctx context.Context, tx *sql.Tx, boardID int, changes []models.ElementChange, current, target []*models.BoardElement, applied int64) (bool, error) {
+	live := map[int]int64{}
+	rows, err := tx.QueryContext(ctx, `SELECT id, version FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL`, boardID)
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		var id int
+		var version int64
+		if err := rows.Scan(&id, &version); err != nil {
+			rows.Close()
+			return false, err
+		}
+		live[id] = version
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	ids, removed, changed := make([]int64, 0, len(changes)), []int64{}, []int64{}
+	for i, change := range changes {
+		version, exists := live[change.ID]
+		if exists != (current[i] != nil) {
+			return false, nil
+		}
+		if exists && version != applied {
+			changed = append(changed, int64(change.ID))
+		}
+		ids = append(ids, int64(change.ID))
+	}
+	if len(changed) > 0 {
+		elements, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND id = ANY($2::int[]) AND deleted_at IS NULL`, boardID, pq.Array(changed))
+		if err != nil {
+			return false, err
+		}
+		actual := make(map[int]models.BoardElement, len(elements))
+		for _, element := range elements {
+			actual[element.ID] = element
+		}
+		for i, change := range changes {
+			if current[i] == nil || live[change.ID] == applied {
+				continue
+			}
+			if element, ok := actual[change.ID]; !ok || !sameElement(element, *current[i]) {
+				return false, nil
+			}
+		}
+	}
+
+	for i, change := range changes {
+		if target[i] == nil {
+			delete(live, change.ID)
+			removed = append(removed, int64(change.ID))
+		} else {
+			live[change.ID] = applied
+		}
+	}
+
+	for _, element := range target {
+		if element == nil {
+			continue
+		}
+		source, dest := connectorRefs(element.Connector)
+		for _, ref := range []*int{element.ParentID, source, dest} {
+			if ref == nil {
+				continue
+			}
+			if _, exists := live[*ref]; !exists {
+				return false, nil
+			}
+		}
+	}
+
+	// Элементы вне операции не должны ссылаться на удаляемые ею элементы
+	var referenced bool
+	query := `SELECT EXISTS (SELECT 1 FROM board_elements
+                             WHERE board_id = $1 AND NOT (id = ANY($2::int[])) AND deleted_at IS NULL
+                               AND (parent_id = ANY($3::int[]) OR source_element_id = ANY($3::int[]) OR target_element_id = ANY($3::int[])))`
+	if err := tx.QueryRowContext(ctx, query, boardID, pq.Array(ids), pq.Array(removed)).Scan(&referenced); err != nil {
+		return false, err
+	}
+	return !referenced, nil
+}
+
+// tombstonedAt возвращает, какие из элементов удалены в версии доски version
+func tombstonedAt(ctx context.Context, q querier, boardID int, ids []int64, version int64) (map[int]bool, error) {
+	rows, err := q.QueryContext(ctx, `SELECT element_id FROM board_element_tombstones WHERE board_id = $1 AND element_id = ANY($2::int[]) AND version = $3`, boardID, pq.Array(ids), version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		deleted[id] = true
+	}
+	return deleted, rows.Err()
+}
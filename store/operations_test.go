@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"micromiro/database"
+	"micromiro/models"
+	"os"
+	"testing"
+	"time"
+)
+
+// testDB подключается к тестовой базе из TEST_DATABASE_URL и применяет миграции.
+// Без переменной тест пропускается.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return db
+}
+
+// testUser создает пользователя, которого тест удаляет по завершении
+func testUser(t *testing.T, db *sql.DB, name string) int {
+	t.Helper()
+	now := time.Now()
+	suffix := fmt.Sprintf("%s%d", name, now.UnixNano())
+	user := &models.User{Username: suffix, Email: suffix + "@example.com", Password: "-", RoleID: 1, CreatedAt: now, UpdatedAt: now}
+	if err := NewUserStore(db).CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE id = $1`, user.ID) })
+	return user.ID
+}
+
+func TestUndoRedoOperations(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	boards := NewBoardStore(db)
+
+	userID := testUser(t, db, "undo")
+	otherID := testUser(t, db, "other")
+
+	now := time.Now()
+	board := &models.Board{Title: "Отмена", CreatorID: userID, CreatedAt: now, UpdatedAt: now}
+	if err := boards.CreateBoard(ctx, board, fmt.Sprintf("undo-%d", now.UnixNano())); err != nil {
+		t.Fatalf("CreateBoard: %v", err)
+	}
+	t.Cleanup(func() {
+		boards.DeleteBoard(ctx, board.ID, 0)
+		boards.PurgeBoard(ctx, board.ID, userID)
+	})
+
+	element := &models.BoardElement{BoardID: board.ID, Type: "sticky", Content: "0", Width: 100, Height: 100, CreatedAt: now, UpdatedAt: now}
+	if err := boards.CreateElement(ctx, element, userID); err != nil {
+		t.Fatalf("CreateElement: %v", err)
+	}
+	setContent := func(t *testing.T, userID int, content string) {
+		t.Helper()
+		_, _, err := boards.ModifyElement(ctx, board.ID, element.ID, UpdateElementOptions{UserID: userID}, func(element *models.BoardElement) error {
+			element.Content = content
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ModifyElement(%q): %v", content, err)
+		}
+	}
+	for _, content := range []string{"1", "2", "3"} {
+		setContent(t, userID, content)
+	}
+
+	// replay отменяет или повторяет операцию и проверяет текст элемента после неё;
+	// пустой want - элемента на доске нет
+	replay := func(t *testing.T, undo bool, want string) {
+		t.Helper()
+		step, name := boards.RedoOperation, "RedoOperation"
+		if undo {
+			step, name = boards.UndoOperation, "UndoOperation"
+		}
+		result, err := step(ctx, board.ID, userID)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Skipped != 0 {
+			t.Errorf("пропущено операций: %d, ожидалось 0", result.Skipped)
+		}
+		got, err := boards.GetElement(ctx, board.ID, element.ID)
+		switch {
+		case want == "" && !errors.Is(err, ErrNotFound):
+			t.Fatalf("элемент остался на доске: %v", err)
+		case want == "":
+		case err != nil:
+			t.Fatalf("GetElement: %v", err)
+		case got.Content != want:
+			t.Fatalf("текст %q, ожидалось %q", got.Content, want)
+		}
+	}
+
+	t.Run("несколько отмен и повторов одного элемента", func(t *testing.T) {
+		for _, want := range []string{"2", "1", "0", ""} {
+			replay(t, true, want)
+		}
+		for _, want := range []string{"0", "1", "2"} {
+			replay(t, false, want)
+		}
+		replay(t, true, "1")
+		replay(t, false, "2")
+		replay(t, false, "3")
+		if _, err := boards.RedoOperation(ctx, board.ID, userID); !errors.Is(err, ErrNoOperation) {
+			t.Fatalf("повтор без отмененных операций: %v, ожидалось %v", err, ErrNoOperation)
+		}
+	})
+
+	t.Run("изменение другого пользователя", func(t *testing.T) {
+		replay(t, true, "2")
+		setContent(t, otherID, "чужой")
+		result, err := boards.UndoOperation(ctx, board.ID, userID)
+		if !errors.Is(err, ErrNoOperation) {
+			t.Fatalf("отмена после чужого изменения: %+v, %v, ожидалось %v", result, err, ErrNoOperation)
+		}
+		got, err := boards.GetElement(ctx, board.ID, element.ID)
+		if err != nil {
+			t.Fatalf("GetElement: %v", err)
+		}
+		if got.Content != "чужой" {
+			t.Errorf("текст %q, чужое изменение потеряно", got.Content)
+		}
+	})
+}
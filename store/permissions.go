@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+)
+
+func (s *boardStore) ListPermissions(ctx context.Context, boardID int) ([]models.BoardPermission, error) {
+	query := `SELECT bp.id, bp.board_id, bp.user_id, u.username, u.email, bp.can_edit, bp.created_at, bp.updated_at
+              FROM board_permissions bp
+              JOIN users u ON u.id = bp.user_id
+              WHERE bp.board_id = $1
+              ORDER BY bp.created_at`
+
+	rows, err := s.db.QueryContext(ctx, query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []models.BoardPermission{}
+	for rows.Next() {
+		var permission models.BoardPermission
+		if err := rows.Scan(&permission.ID, &permission.BoardID, &permission.UserID, &permission.Username, &permission.Email, &permission.CanEdit, &permission.CreatedAt, &permission.UpdatedAt); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+// GrantPermission добавляет запись о доступе. Если доступ уже есть, возвращает ErrConflict.
+func (s *boardStore) GrantPermission(ctx context.Context, permission *models.BoardPermission) error {
+	query := `INSERT INTO board_permissions (board_id, user_id, can_edit, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5)
+              ON CONFLICT (board_id, user_id) DO NOTHING
+              RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, permission.BoardID, permission.UserID, permission.CanEdit, permission.CreatedAt, permission.UpdatedAt).Scan(&permission.ID)
+	if err == sql.ErrNoRows {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *boardStore) UpdatePermission(ctx context.Context, boardID, userID int, canEdit bool) error {
+	query := `UPDATE board_permissions SET can_edit = $1, updated_at = $2 WHERE board_id = $3 AND user_id = $4`
+	result, err := s.db.ExecContext(ctx, query, canEdit, time.Now(), boardID, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (s *boardStore) RevokePermission(ctx context.Context, boardID, userID int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM board_permissions WHERE board_id = $1 AND user_id = $2`, boardID, userID)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"micromiro/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// deferElementRefs откладывает проверку ссылок между элементами до конца транзакции,
+// чтобы элементы можно было записывать в любом порядке
+const deferElementRefs = `SET CONSTRAINTS board_elements_parent_fkey, board_elements_source_element_fkey, board_elements_target_element_fkey DEFERRED`
+
+const revisionColumns = `r.id, r.board_id, r.board_version, r.kind, r.label, r.author_id, u.username, jsonb_array_length(r.elements), r.created_at`
+
+func scanRevision(row scanner) (*models.BoardRevision, error) {
+	var revision models.BoardRevision
+	err := row.Scan(&revision.ID, &revision.BoardID, &revision.BoardVersion, &revision.Kind, &revision.Label, &revision.AuthorID, &revision.AuthorName, &revision.ElementCount, &revision.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// CreateRevision сохраняет снимок текущих элементов доски
+func (s *boardStore) CreateRevision(ctx context.Context, boardID int, authorID *int, kind, label string) (*models.BoardRevision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Разделяемая блокировка доски не дает изменить элементы, пока снимается снимок
+	var version int64
+	err = tx.QueryRowContext(ctx, `SELECT version FROM boards WHERE id = $1 AND deleted_at IS NULL FOR SHARE`, boardID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	elements, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL ORDER BY z_index, id`, boardID)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := insertRevision(ctx, tx, boardID, version, authorID, kind, label, elements)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// ListRevisions возвращает ревизии доски, новые первыми
+func (s *boardStore) ListRevisions(ctx context.Context, boardID int) ([]models.BoardRevision, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+revisionColumns+`
+                                         FROM board_revisions r
+                                         LEFT JOIN users u ON u.id = r.author_id
+                                         WHERE r.board_id = $1
+                                         ORDER BY r.created_at DESC, r.id DESC`, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.BoardRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision возвращает ревизию доски вместе с элементами из снимка
+func (s *boardStore) GetRevision(ctx context.Context, boardID, revisionID int) (*models.BoardRevision, []models.BoardElement, error) {
+	var snapshot []byte
+	revision, err := scanRevision(s.db.QueryRowContext(ctx, `SELECT `+revisionColumns+`
+                                                            FROM board_revisions r
+                                                            LEFT JOIN users u ON u.id = r.author_id
+                                                            WHERE r.id = $1 AND r.board_id = $2`, revisionID, boardID))
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.db.QueryRowContext(ctx, `SELECT elements FROM board_revisions WHERE id = $1`, revisionID).Scan(&snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	elements := []models.BoardElement{}
+	if err := json.Unmarshal(snapshot, &elements); err != nil {
+		return nil, nil, err
+	}
+	return revision, elements, nil
+}
+
+// RestoreRevision делает элементы ревизии текущими в одной транзакции: лишние элементы
+// переносятся в корзину пользователя userID, измененные и удаленные восстанавливаются
+// с прежними ID, совпадающие не трогаются.
+// Перед этим текущее состояние сохраняется ревизией вида restore, она и возвращается
+// вместе с новой версией доски. Заблокированные элементы меняются, только если allowLocked.
+func (s *boardStore) RestoreRevision(ctx context.Context, boardID, revisionID, userID int, allowLocked bool) (*models.BoardRevision, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snapshot []byte
+	err = tx.QueryRowContext(ctx, `SELECT elements FROM board_revisions WHERE id = $1 AND board_id = $2`, revisionID, boardID).Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var target []models.BoardElement
+	if err := json.Unmarshal(snapshot, &target); err != nil {
+		return nil, 0, err
+	}
+
+	current, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL ORDER BY z_index, id`, boardID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Сравниваем текущие элементы со снимком
+	targetByID := make(map[int]models.BoardElement, len(target))
+	for _, element := range target {
+		targetByID[element.ID] = element
+	}
+	currentByID := make(map[int]models.BoardElement, len(current))
+	removed := []int64{}
+	for _, element := range current {
+		currentByID[element.ID] = element
+		restored, ok := targetByID[element.ID]
+		if ok && sameElement(element, restored) {
+			continue
+		}
+		if element.Locked && !allowLocked {
+			return nil, 0, ErrLocked
+		}
+		if !ok {
+			removed = append(removed, int64(element.ID))
+		}
+	}
+
+	before, err := insertRevision(ctx, tx, boardID, version-1, &userID, models.RevisionRestore, "", current)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Ссылки между элементами станут согласованными только после всех изменений
+	if _, err := tx.ExecContext(ctx, deferElementRefs); err != nil {
+		return nil, 0, err
+	}
+	// Элементы, которых нет в ревизии, попадают в корзину того, кто восстанавливает ревизию
+	if err := trashElements(ctx, tx, boardID, 0, removed, userID, version); err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	restoredIDs := []int64{}
+	for _, element := range target {
+		if existing, ok := currentByID[element.ID]; ok && sameElement(existing, element) {
+			continue
+		}
+		element.BoardID, element.Version, element.UpdatedAt = boardID, version, now
+		if err := upsertElement(ctx, tx, &element); err != nil {
+			return nil, 0, err
+		}
+		restoredIDs = append(restoredIDs, int64(element.ID))
+	}
+	// Восстановленные элементы больше не считаются удаленными в ленте изменений
+	if _, err := tx.ExecContext(ctx, `DELETE FROM board_element_tombstones WHERE element_id = ANY($1::int[])`, pq.Array(restoredIDs)); err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, 0, ErrInvalidReference
+		}
+		return nil, 0, err
+	}
+	return before, version, nil
+}
+
+// ListBoardsChangedSinceRevision возвращает доски, изменившиеся после последней ревизии.
+// Нетронутые новые доски (версия 1) пропускаются.
+func (s *boardStore) ListBoardsChangedSinceRevision(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT b.id FROM boards b
+                                         WHERE b.deleted_at IS NULL
+                                           AND b.version > COALESCE((SELECT MAX(r.board_version) FROM board_revisions r WHERE r.board_id = b.id), 1)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeAutoRevisions удаляет автоматические ревизии, сохраненные раньше before, кроме keep
+// последних автоматических ревизий каждой доски. Ревизии пользователей и ревизии перед
+// восстановлением не удаляются. Возвращает число удаленных ревизий.
+func (s *boardStore) PurgeAutoRevisions(ctx context.Context, before time.Time, keep int) (int, error) {
+	query := `DELETE FROM board_revisions r
+              USING (SELECT id, ROW_NUMBER() OVER (PARTITION BY board_id ORDER BY created_at DESC, id DESC) AS position
+                     FROM board_revisions WHERE kind = $1) ranked
+              WHERE r.id = ranked.id AND ranked.position > $3 AND r.created_at < $2`
+	result, err := s.db.ExecContext(ctx, query, models.RevisionAuto, before, keep)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, boardID int, version int64, authorID *int, kind, label string, elements []models.BoardElement) (*models.BoardRevision, error) {
+	snapshot, err := json.Marshal(elements)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := models.BoardRevision{BoardID: boardID, BoardVersion: version, Kind: kind, Label: label, AuthorID: authorID, ElementCount: len(elements), CreatedAt: time.Now()}
+	query := `INSERT INTO board_revisions (board_id, board_version, kind, label, author_id, elements, created_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)
+              RETURNING id, (SELECT username FROM users WHERE id = $5)`
+	err = tx.QueryRowContext(ctx, query, boardID, version, kind, label, authorID, snapshot, revision.CreatedAt).Scan(&revision.ID, &revision.AuthorName)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// upsertElement записывает элемент с его прежним ID: обновляет существующий, возвращает
+// элемент из корзины или создает окончательно удаленный заново
+func upsertElement(ctx context.Context, tx *sql.Tx, element *models.BoardElement) error {
+	sourceID, targetID := connectorRefs(element.Connector)
+	query := `INSERT INTO board_elements (id, board_id, type, content, position_x, position_y, width, height, style, z_index, rotation, locked,
+                                          source_element_id, target_element_id, connector, parent_id, version, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+              ON CONFLICT (id) DO UPDATE SET type = EXCLUDED.type, content = EXCLUDED.content, position_x = EXCLUDED.position_x, position_y = EXCLUDED.position_y,
+                                             width = EXCLUDED.width, height = EXCLUDED.height, style = EXCLUDED.style, z_index = EXCLUDED.z_index,
+                                             rotation = EXCLUDED.rotation, locked = EXCLUDED.locked, source_element_id = EXCLUDED.source_element_id,
+                                             target_element_id = EXCLUDED.target_element_id, connector = EXCLUDED.connector, parent_id = EXCLUDED.parent_id,
+                                             version = EXCLUDED.version, updated_at = EXCLUDED.updated_at,
+                                             deleted_at = NULL, deleted_by = NULL, trash_root_id = NULL, trashed_z_index = NULL
+              WHERE board_elements.board_id = EXCLUDED.board_id`
+	result, err := tx.ExecContext(ctx, query, element.ID, element.BoardID, element.Type, element.Content, element.PositionX, element.PositionY, element.Width, element.Height, element.Style,
+		element.ZIndex, element.Rotation, element.Locked, sourceID, targetID, element.Connector, element.ParentID, element.Version, element.CreatedAt, element.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// sameElement сравнивает содержимое элементов без учета версии и времени изменения
+func sameElement(a, b models.BoardElement) bool {
+	a.Version, b.Version = 0, 0
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	a.CreatedAt, b.CreatedAt = a.CreatedAt.UTC(), b.CreatedAt.UTC()
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(left, right)
+}
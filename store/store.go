@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"micromiro/models"
+	"time"
+)
+
+var (
+	// ErrNotFound возвращается, когда запись не найдена
+	ErrNotFound = errors.New("not found")
+	// ErrConflict возвращается при нарушении уникальности
+	ErrConflict = errors.New("conflict")
+	// ErrLocked возвращается при попытке изменить заблокированный элемент
+	ErrLocked = errors.New("element is locked")
+	// ErrLockChange возвращается, когда блокировку элемента меняет не создатель доски
+	ErrLockChange = errors.New("lock change not allowed")
+	// ErrVersionMismatch возвращается, когда запись изменилась после версии из If-Match
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrInvalidCursor возвращается для курсора ленты изменений, которого не было у доски
+	// или который старше хранимых надгробий
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidReference возвращается, когда коннектор ссылается на элемент другой доски
+	// или на несуществующий элемент
+	ErrInvalidReference = errors.New("invalid element reference")
+	// ErrInvalidParent возвращается, когда родителем элемента указана не рамка или группа
+	// той же доски, либо вложенность образует цикл
+	ErrInvalidParent = errors.New("invalid parent element")
+	// ErrInviteExpired возвращается для приглашения с истекшим сроком
+	ErrInviteExpired = errors.New("invite expired")
+	// ErrInviteUsedUp возвращается для приглашения, исчерпавшего лимит использований
+	ErrInviteUsedUp = errors.New("invite used up")
+	// ErrNoOperation возвращается, когда в журнале нет операции для отмены или повтора
+	ErrNoOperation = errors.New("no operation")
+	// ErrTokenExpired возвращается для истекшего токена обновления
+	ErrTokenExpired = errors.New("token expired")
+	// ErrTokenReused возвращается при повторном предъявлении уже обмененного токена обновления
+	ErrTokenReused = errors.New("token reused")
+	// ErrLimitExceeded возвращается, когда у пользователя уже слишком много действующих записей
+	ErrLimitExceeded = errors.New("limit exceeded")
+)
+
+// Access описывает права пользователя на доску
+type Access struct {
+	CreatorID int
+	IsCreator bool
+	CanView   bool
+	CanEdit   bool
+}
+
+// BoardStore - хранилище досок, их элементов, разрешений и приглашений
+type BoardStore interface {
+	CreateBoard(ctx context.Context, board *models.Board, publicSlug string) error
+	ImportBoard(ctx context.Context, board *models.Board, publicSlug string, elements []models.BoardElement) (map[int]int, error)
+	ListBoards(ctx context.Context, userID int) ([]models.Board, error)
+	GetBoard(ctx context.Context, boardID int) (*models.Board, error)
+	GetAccess(ctx context.Context, boardID, userID int) (Access, error)
+	UpdateBoard(ctx context.Context, boardID int, req models.UpdateBoardRequest, ifMatch int64) (int64, error)
+	DeleteBoard(ctx context.Context, boardID int, ifMatch int64) error
+
+	ListElements(ctx context.Context, boardID int) ([]models.BoardElement, error)
+	GetElement(ctx context.Context, boardID, elementID int) (*models.BoardElement, error)
+	CreateElement(ctx context.Context, element *models.BoardElement, userID int) error
+	ModifyElement(ctx context.Context, boardID, elementID int, opts UpdateElementOptions, modify func(element *models.BoardElement) error) (*models.BoardElement, []models.BoardElement, error)
+	DeleteElement(ctx context.Context, boardID, elementID int, opts DeleteElementOptions) (*ElementDeletion, error)
+	ReorderElement(ctx context.Context, boardID, elementID, userID int, action string, allowLocked bool) ([]models.ElementOrder, error)
+	ApplyElementBatch(ctx context.Context, boardID, userID int, ops []ElementOp, allowLocked bool) ([]ElementOpResult, error)
+	UndoOperation(ctx context.Context, boardID, userID int) (*models.OperationReplay, error)
+	RedoOperation(ctx context.Context, boardID, userID int) (*models.OperationReplay, error)
+	ListChanges(ctx context.Context, boardID int, since int64) (*models.BoardChanges, error)
+
+	CreateRevision(ctx context.Context, boardID int, authorID *int, kind, label string) (*models.BoardRevision, error)
+	ListRevisions(ctx context.Context, boardID int) ([]models.BoardRevision, error)
+	GetRevision(ctx context.Context, boardID, revisionID int) (*models.BoardRevision, []models.BoardElement, error)
+	RestoreRevision(ctx context.Context, boardID, revisionID, userID int, allowLocked bool) (*models.BoardRevision, int64, error)
+	ListBoardsChangedSinceRevision(ctx context.Context) ([]int, error)
+	PurgeAutoRevisions(ctx context.Context, before time.Time, keep int) (int, error)
+
+	ListPermissions(ctx context.Context, boardID int) ([]models.BoardPermission, error)
+	GrantPermission(ctx context.Context, permission *models.BoardPermission) error
+	UpdatePermission(ctx context.Context, boardID, userID int, canEdit bool) error
+	RevokePermission(ctx context.Context, boardID, userID int) error
+
+	CreateInvite(ctx context.Context, invite *models.BoardInvite) error
+	ListActiveInvites(ctx context.Context, boardID int, now time.Time) ([]models.BoardInvite, error)
+	DeleteInvite(ctx context.Context, boardID, inviteID int) error
+	AcceptInvite(ctx context.Context, token string, userID int, now time.Time) (*InviteAcceptance, error)
+
+	GetPublicBoard(ctx context.Context, slug string) (*models.Board, error)
+	GetPublicLink(ctx context.Context, boardID int) (*models.PublicLink, error)
+	SetPublicSlug(ctx context.Context, boardID int, slug string) (*models.PublicLink, error)
+	EnsurePublicSlug(ctx context.Context, boardID int, slug string) (*models.PublicLink, error)
+
+	ListTrash(ctx context.Context, userID int) (*models.Trash, error)
+	RestoreBoard(ctx context.Context, boardID, userID int) (*models.Board, error)
+	PurgeBoard(ctx context.Context, boardID, userID int) error
+	RestoreElement(ctx context.Context, boardID, elementID, deletedBy int) ([]models.BoardElement, error)
+	PurgeElement(ctx context.Context, boardID, elementID, deletedBy int) error
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+
+	CreateExportJob(ctx context.Context, job *models.ExportJob, boardVersion int64, maxActive int) error
+	GetExportJob(ctx context.Context, boardID, jobID int) (*models.ExportJob, error)
+	GetExportResult(ctx context.Context, boardID, jobID int) ([]byte, error)
+	ClaimExportJob(ctx context.Context, staleBefore time.Time, maxAttempts int) (*models.ExportJob, error)
+	FinishExportJob(ctx context.Context, jobID int, boardVersion int64, result []byte, failure string) error
+	PurgeExportJobs(ctx context.Context, before time.Time) (int, error)
+
+	NoteThumbnailChanges(ctx context.Context) (int, error)
+	ListBoardsNeedingThumbnail(ctx context.Context) ([]int, error)
+	SaveThumbnail(ctx context.Context, boardID int, boardVersion int64, image []byte) error
+	GetThumbnail(ctx context.Context, boardID int) ([]byte, int64, error)
+}
+
+// UserStore - хранилище пользователей
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	FindUser(ctx context.Context, email, username string) (*models.User, error)
+	GetUserByID(ctx context.Context, userID int) (*models.User, error)
+}
+
+// TokenStore - хранилище сессий, токенов обновления и сброса пароля и черного списка токенов доступа
+type TokenStore interface {
+	CreateSession(ctx context.Context, session *models.Session, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken, now time.Time) error
+	ListSessions(ctx context.Context, userID int, now time.Time) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID int, sessionID string, now time.Time) error
+	RevokeUserSessions(ctx context.Context, userID int, now time.Time) error
+	IsTokenRevoked(ctx context.Context, jti, sessionID string) (bool, error)
+	TouchSession(ctx context.Context, sessionID string, now time.Time) error
+	PurgeTokens(ctx context.Context, now time.Time) (int, error)
+
+	CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt, now time.Time, limit int) error
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (int, error)
+}
+
+// InviteAcceptance - результат принятия приглашения
+type InviteAcceptance struct {
+	BoardID int
+	// IsCreator - приглашение открыл сам создатель доски, доступ не менялся
+	IsCreator bool
+	// Granted - доступ выдан или расширен, использование засчитано
+	Granted bool
+}
+
+// UpdateElementOptions - условия изменения элемента
+type UpdateElementOptions struct {
+	// AllowLocked разрешает менять заблокированный элемент и саму блокировку
+	AllowLocked bool
+	// IfMatch - ожидаемая версия элемента, 0 - любая. При несовпадении возвращается ErrVersionMismatch.
+	IfMatch int64
+	// UserID - автор изменения, получает операцию в журнал отмены. 0 - журнал не ведется.
+	UserID int
+}
+
+// DeleteElementOptions - условия удаления и что делать с элементами, связанными с удаляемым
+type DeleteElementOptions struct {
+	// AllowLocked разрешает удалять заблокированные элементы
+	AllowLocked bool
+	// IfMatch - ожидаемая версия элемента, 0 - любая. При несовпадении возвращается ErrVersionMismatch.
+	IfMatch int64
+	// DetachConnectors оставляет прикрепленные коннекторы на доске вместо удаления
+	DetachConnectors bool
+	// CascadeChildren удаляет вложенные элементы вместо их переноса на верхний уровень
+	CascadeChildren bool
+	// UserID - пользователь, удаливший элемент: показывается в корзине и получает операцию
+	// в журнал отмены. 0 - неизвестен, журнал не ведется.
+	UserID int
+}
+
+// ElementDeletion - элементы, затронутые удалением
+type ElementDeletion struct {
+	// DeletedChildren - вложенные элементы, удаленные вместе с родителем
+	DeletedChildren []int
+	// OrphanedChildren - вложенные элементы, ставшие элементами верхнего уровня
+	OrphanedChildren []models.BoardElement
+	// DeletedConnectors - коннекторы, удаленные вместе с элементами
+	DeletedConnectors []int
+	// DetachedConnectors - коннекторы, чьи концы откреплены от удаленных элементов
+	DetachedConnectors []models.BoardElement
+}
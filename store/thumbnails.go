@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NoteThumbnailChanges запоминает текущую версию досок, изменившихся с прошлой проверки.
+// Возвращает число таких досок.
+func (s *boardStore) NoteThumbnailChanges(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO board_thumbnails (board_id, seen_version)
+                                       SELECT b.id, b.version
+                                       FROM boards b
+                                       LEFT JOIN board_thumbnails t ON t.board_id = b.id
+                                       WHERE b.deleted_at IS NULL AND (t.board_id IS NULL OR t.seen_version <> b.version)
+                                       ON CONFLICT (board_id) DO UPDATE SET seen_version = EXCLUDED.seen_version`)
+	if err != nil {
+		return 0, err
+	}
+	noted, err := res.RowsAffected()
+	return int(noted), err
+}
+
+// ListBoardsNeedingThumbnail возвращает доски, превью которых устарело, а сами они
+// не менялись с прошлой проверки
+func (s *boardStore) ListBoardsNeedingThumbnail(ctx context.Context) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT t.board_id
+                                         FROM board_thumbnails t
+                                         JOIN boards b ON b.id = t.board_id
+                                         WHERE b.deleted_at IS NULL AND t.seen_version = b.version
+                                           AND t.board_version IS DISTINCT FROM b.version
+                                         ORDER BY t.board_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SaveThumbnail сохраняет превью, нарисованное с версии доски boardVersion.
+// Пустой image означает, что превью нарисовать не удалось и вместо него показывается заглушка.
+func (s *boardStore) SaveThumbnail(ctx context.Context, boardID int, boardVersion int64, image []byte) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE board_thumbnails SET board_version = $1, image = $2, updated_at = $3 WHERE board_id = $4`,
+		boardVersion, image, time.Now(), boardID)
+	return err
+}
+
+// GetThumbnail возвращает превью доски и версию, с которой оно нарисовано.
+// Если превью еще нет, возвращает ErrNotFound.
+func (s *boardStore) GetThumbnail(ctx context.Context, boardID int) ([]byte, int64, error) {
+	var image []byte
+	var version int64
+	err := s.db.QueryRowContext(ctx, `SELECT image, board_version FROM board_thumbnails
+                                      WHERE board_id = $1 AND image IS NOT NULL`, boardID).Scan(&image, &version)
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrNotFound
+	}
+	return image, version, err
+}
@@ -0,0 +1,291 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+)
+
+type tokenStore struct {
+	db *sql.DB
+}
+
+// NewTokenStore создает хранилище токенов поверх общего пула соединений
+func NewTokenStore(db *sql.DB) TokenStore {
+	return &tokenStore{db: db}
+}
+
+const refreshTokenColumns = `id, user_id, session_id, token_hash, access_jti, access_expires_at, expires_at, created_at, used_at, revoked_at`
+
+func scanRefreshToken(row scanner) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := row.Scan(&token.ID, &token.UserID, &token.SessionID, &token.TokenHash, &token.AccessJTI, &token.AccessExpiresAt,
+		&token.ExpiresAt, &token.CreatedAt, &token.UsedAt, &token.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateSession сохраняет новую сессию вместе с её первым токеном обновления
+func (s *tokenStore) CreateSession(ctx context.Context, session *models.Session, token *models.RefreshToken) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+                                  VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastUsedAt, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := insertRefreshToken(ctx, tx, token); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertRefreshToken(ctx context.Context, q querier, token *models.RefreshToken) error {
+	return q.QueryRowContext(ctx, `INSERT INTO refresh_tokens (user_id, session_id, token_hash, access_jti, access_expires_at, expires_at, created_at)
+                                   VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		token.UserID, token.SessionID, token.TokenHash, token.AccessJTI, token.AccessExpiresAt, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+}
+
+// GetRefreshToken находит токен обновления по хешу
+func (s *tokenStore) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return scanRefreshToken(s.db.QueryRowContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1`, tokenHash))
+}
+
+// RotateRefreshToken обменивает токен обновления на следующий токен той же сессии и продлевает её.
+// Для неизвестного или отозванного токена возвращает ErrNotFound, для истекшего - ErrTokenExpired.
+// Повторное предъявление уже обмененного токена завершает сессию и возвращает ErrTokenReused.
+func (s *tokenStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка не дает двум параллельным запросам обменять один токен дважды
+	current, err := scanRefreshToken(tx.QueryRowContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash))
+	if err != nil {
+		return err
+	}
+	switch {
+	case current.RevokedAt != nil:
+		return ErrNotFound
+	case current.UsedAt != nil:
+		if err := revokeSessions(ctx, tx, now, `id = $2`, current.SessionID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return ErrTokenReused
+	case !current.ExpiresAt.After(now):
+		return ErrTokenExpired
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2`, now, current.ID); err != nil {
+		return err
+	}
+	next.UserID, next.SessionID = current.UserID, current.SessionID
+	if err := insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET last_used_at = $1, expires_at = $2 WHERE id = $3`, now, next.ExpiresAt, next.SessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListSessions возвращает действующие сессии пользователя, недавно использованные первыми
+func (s *tokenStore) ListSessions(ctx context.Context, userID int, now time.Time) ([]models.Session, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, user_agent, ip, created_at, last_used_at, expires_at
+                                         FROM sessions
+                                         WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+                                         ORDER BY last_used_at DESC, created_at DESC`, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession завершает действующую сессию пользователя: отзывает её токены обновления
+// и добавляет выданные по ним токены доступа в черный список.
+// Если у пользователя нет такой действующей сессии, возвращает ErrNotFound.
+func (s *tokenStore) RevokeSession(ctx context.Context, userID int, sessionID string, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > $3 FOR UPDATE`,
+		sessionID, userID, now).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := revokeSessions(ctx, tx, now, `id = $2`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserSessions завершает все сессии пользователя
+func (s *tokenStore) RevokeUserSessions(ctx context.Context, userID int, now time.Time) error {
+	return revokeSessions(ctx, s.db, now, `user_id = $2`, userID)
+}
+
+// IsTokenRevoked сообщает, что токен доступа jti находится в черном списке
+// или выдан в уже завершенной сессии
+func (s *tokenStore) IsTokenRevoked(ctx context.Context, jti, sessionID string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+                                          OR EXISTS (SELECT 1 FROM sessions WHERE id = $2 AND revoked_at IS NOT NULL)`, jti, sessionID).Scan(&revoked)
+	return revoked, err
+}
+
+// TouchSession отмечает время последнего использования сессии
+func (s *tokenStore) TouchSession(ctx context.Context, sessionID string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_used_at = $1 WHERE id = $2 AND last_used_at < $1`, now, sessionID)
+	return err
+}
+
+// PurgeTokens удаляет истекшие сессии, токены обновления и сброса пароля, а также записи
+// черного списка об истекших токенах доступа. Возвращает число удаленных записей.
+func (s *tokenStore) PurgeTokens(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	for _, query := range []string{
+		`DELETE FROM sessions WHERE expires_at < $1`,
+		`DELETE FROM refresh_tokens WHERE expires_at < $1`,
+		`DELETE FROM revoked_tokens WHERE expires_at < $1`,
+		`DELETE FROM password_reset_tokens WHERE expires_at < $1`,
+	} {
+		res, err := s.db.ExecContext(ctx, query, now)
+		if err != nil {
+			return purged, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(affected)
+	}
+	return purged, nil
+}
+
+// revokeSessions завершает действующие сессии, выбранные условием condition: отзывает их
+// токены обновления и добавляет в черный список еще не истекшие токены доступа, выданные
+// по ним. В condition параметр $1 занят временем отзыва, аргументы args начинаются с $2.
+func revokeSessions(ctx context.Context, q querier, now time.Time, condition string, args ...interface{}) error {
+	query := `WITH ended AS (
+                  UPDATE sessions SET revoked_at = $1
+                  WHERE revoked_at IS NULL AND ` + condition + `
+                  RETURNING id
+              ), revoked AS (
+                  UPDATE refresh_tokens SET revoked_at = $1
+                  WHERE revoked_at IS NULL AND session_id IN (SELECT id FROM ended)
+                  RETURNING access_jti, access_expires_at
+              )
+              INSERT INTO revoked_tokens (jti, expires_at)
+              SELECT access_jti, access_expires_at FROM revoked WHERE access_expires_at > $1
+              ON CONFLICT (jti) DO NOTHING`
+	_, err := q.ExecContext(ctx, query, append([]interface{}{now}, args...)...)
+	return err
+}
+
+// CreatePasswordReset сохраняет токен сброса пароля. Если у пользователя уже limit
+// неиспользованных и неистекших токенов, новый не создается и возвращается ErrLimitExceeded.
+func (s *tokenStore) CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt, now time.Time, limit int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка пользователя не дает параллельным запросам обойти лимит
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var active int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL AND expires_at > $2`,
+		userID, now).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active >= limit {
+		return ErrLimitExceeded
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4)`,
+		userID, tokenHash, expiresAt, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetPassword использует токен сброса: меняет пароль пользователя на passwordHash,
+// делает недействительными все его токены сброса и завершает все его сессии.
+// Возвращает ID пользователя, чей пароль изменен.
+// Для неизвестного, уже использованного или истекшего токена возвращает ErrNotFound.
+func (s *tokenStore) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка не дает использовать токен дважды параллельными запросами
+	var userID int
+	err = tx.QueryRowContext(ctx, `SELECT user_id FROM password_reset_tokens
+                                   WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+                                   FOR UPDATE`, tokenHash, now).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET password = $1, updated_at = $2 WHERE id = $3`, passwordHash, now, userID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE password_reset_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, now, userID); err != nil {
+		return 0, err
+	}
+	if err := revokeSessions(ctx, tx, now, `user_id = $2`, userID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
@@ -0,0 +1,441 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ListTrash возвращает корзину пользователя: доски, которые он создал и удалил, и удаленные
+// элементы досок, где он создатель или сам удалил элемент и всё ещё может редактировать доску.
+// Элементы, удаленные вместе с другим элементом, в списке не показываются и восстанавливаются вместе с ним.
+func (s *boardStore) ListTrash(ctx context.Context, userID int) (*models.Trash, error) {
+	trash := &models.Trash{Elements: []models.TrashedElement{}}
+
+	var err error
+	trash.Boards, err = s.queryBoards(ctx, `SELECT `+boardColumns+` FROM boards
+                                            WHERE creator_id = $1 AND deleted_at IS NOT NULL
+                                            ORDER BY deleted_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + elementColumns + `, (SELECT b.title FROM boards b WHERE b.id = e.board_id), e.deleted_at, e.deleted_by,
+                     (SELECT COUNT(*) FROM board_elements r
+                      WHERE r.board_id = e.board_id AND r.trash_root_id = e.id AND r.id != e.id AND r.deleted_at IS NOT NULL)
+              FROM board_elements e
+              WHERE e.deleted_at IS NOT NULL AND e.trash_root_id = e.id
+                AND e.board_id IN (SELECT b.id FROM boards b WHERE b.deleted_at IS NULL
+                    AND (b.creator_id = $1 OR (e.deleted_by = $1 AND EXISTS (
+                        SELECT 1 FROM board_permissions bp WHERE bp.board_id = b.id AND bp.user_id = $1 AND bp.can_edit))))
+              ORDER BY e.deleted_at DESC, e.id DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trashed models.TrashedElement
+		element, err := scanElement(extraScanner{row: rows, extra: []interface{}{&trashed.BoardTitle, &trashed.DeletedAt, &trashed.DeletedBy, &trashed.Related}})
+		if err != nil {
+			return nil, err
+		}
+		trashed.Element = *element
+		trash.Elements = append(trash.Elements, trashed)
+	}
+	return trash, rows.Err()
+}
+
+// RestoreBoard возвращает доску создателя из корзины
+func (s *boardStore) RestoreBoard(ctx context.Context, boardID, userID int) (*models.Board, error) {
+	query := `UPDATE boards SET deleted_at = NULL, version = version + 1
+              WHERE id = $1 AND creator_id = $2 AND deleted_at IS NOT NULL
+              RETURNING ` + boardColumns
+	return scanBoard(s.db.QueryRowContext(ctx, query, boardID, userID))
+}
+
+// PurgeBoard окончательно удаляет доску создателя из корзины вместе с элементами,
+// ревизиями, разрешениями и приглашениями
+func (s *boardStore) PurgeBoard(ctx context.Context, boardID, userID int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM boards WHERE id = $1 AND creator_id = $2 AND deleted_at IS NOT NULL FOR UPDATE`, boardID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := destroyBoard(ctx, tx, boardID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreElement возвращает элемент из корзины вместе с элементами, удаленными с ним,
+// поверх остальных элементов в прежнем порядке наложения и с новой версией доски.
+// Концы коннекторов и parent_id, которые ссылаются на элементы вне доски (в корзине
+// или удаленные окончательно), открепляются. Если deletedBy не 0, восстановить можно только элемент, который удалил
+// этот пользователь. Возвращает восстановленные элементы.
+func (s *boardStore) RestoreElement(ctx context.Context, boardID, elementID, deletedBy int) ([]models.BoardElement, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpBoardVersion(ctx, tx, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTrashedElement(ctx, tx, boardID, elementID, deletedBy); err != nil {
+		return nil, err
+	}
+
+	restored, err := queryElements(ctx, tx, `SELECT `+elementColumns+` FROM board_elements
+                                             WHERE board_id = $1 AND trash_root_id = $2 AND deleted_at IS NOT NULL
+                                             ORDER BY trashed_z_index NULLS FIRST, id`, boardID, elementID)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, element := range restored {
+		found = found || element.ID == elementID
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+
+	present := map[int]bool{}
+	idRows, err := tx.QueryContext(ctx, `SELECT id FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL`, boardID)
+	if err != nil {
+		return nil, err
+	}
+	for idRows.Next() {
+		var id int
+		if err := idRows.Scan(&id); err != nil {
+			idRows.Close()
+			return nil, err
+		}
+		present[id] = true
+	}
+	idRows.Close()
+	if err := idRows.Err(); err != nil {
+		return nil, err
+	}
+	for _, element := range restored {
+		present[element.ID] = true
+	}
+
+	var top int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(z_index), 0) FROM board_elements WHERE board_id = $1 AND deleted_at IS NULL`, boardID).Scan(&top); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ids := make([]int64, 0, len(restored))
+	for i := range restored {
+		element := &restored[i]
+		if element.ParentID != nil && !present[*element.ParentID] {
+			element.ParentID = nil
+		}
+		if element.Connector != nil {
+			for _, end := range []*models.ConnectorEnd{&element.Connector.Source, &element.Connector.Target} {
+				if end.ElementID != nil && !present[*end.ElementID] {
+					end.ElementID = nil
+				}
+			}
+		}
+		top++
+		element.ZIndex, element.Version, element.UpdatedAt = top, version, now
+		if err := upsertElement(ctx, tx, element); err != nil {
+			return nil, err
+		}
+		ids = append(ids, int64(element.ID))
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM board_element_tombstones WHERE element_id = ANY($1::int[])`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrInvalidReference
+		}
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeElement окончательно удаляет элемент из корзины вместе с элементами, удаленными с ним.
+// Если deletedBy не 0, удалить можно только элемент, который удалил этот пользователь.
+// Надгробия остаются для ленты изменений до очистки корзины по сроку.
+func (s *boardStore) PurgeElement(ctx context.Context, boardID, elementID, deletedBy int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockBoard(ctx, tx, boardID); err != nil {
+		return err
+	}
+	if err := checkTrashedElement(ctx, tx, boardID, elementID, deletedBy); err != nil {
+		return err
+	}
+	purged, err := destroyTrashedElements(ctx, tx, boardID, `trash_root_id = $2`, elementID)
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// PurgeTrash окончательно удаляет доски и элементы, попавшие в корзину раньше before,
+// и надгробия элементов, удаленных раньше before. Курсор ленты изменений доски,
+// который старше последнего удаленного надгробия, после этого отклоняется.
+// Возвращает число удаленных досок и элементов.
+func (s *boardStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	boardIDs, err := s.queryIDs(ctx, `SELECT DISTINCT board_id FROM board_elements WHERE deleted_at < $1`, before)
+	if err != nil {
+		return purged, err
+	}
+	for _, id := range boardIDs {
+		elements, err := s.purgeExpiredElements(ctx, id, before)
+		if err != nil {
+			return purged, err
+		}
+		purged += elements
+	}
+
+	query := `WITH pruned AS (
+                  DELETE FROM board_element_tombstones WHERE deleted_at < $1 RETURNING board_id, version
+              )
+              UPDATE boards b SET pruned_version = GREATEST(b.pruned_version, p.version)
+              FROM (SELECT board_id, MAX(version) AS version FROM pruned GROUP BY board_id) p
+              WHERE b.id = p.board_id`
+	if _, err := s.db.ExecContext(ctx, query, before); err != nil {
+		return purged, err
+	}
+
+	ids, err := s.queryIDs(ctx, `SELECT id FROM boards WHERE deleted_at < $1`, before)
+	if err != nil {
+		return purged, err
+	}
+	for _, id := range ids {
+		deleted, err := s.purgeExpiredBoard(ctx, id, before)
+		if err != nil {
+			return purged, err
+		}
+		if deleted {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// queryIDs возвращает ID из первой колонки запроса
+func (s *boardStore) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// purgeExpiredElements удаляет элементы доски, попавшие в корзину раньше before
+func (s *boardStore) purgeExpiredElements(ctx context.Context, boardID int, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Доска из корзины удаляется целиком вместе с элементами
+	err = lockBoard(ctx, tx, boardID)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	purged, err := destroyTrashedElements(ctx, tx, boardID, `deleted_at < $2`, before)
+	if err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
+
+// purgeExpiredBoard удаляет доску, если она всё ещё в корзине с момента раньше before
+func (s *boardStore) purgeExpiredBoard(ctx context.Context, boardID int, before time.Time) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `SELECT id FROM boards WHERE id = $1 AND deleted_at < $2 FOR UPDATE`, boardID, before).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := destroyBoard(ctx, tx, boardID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// destroyBoard удаляет доску и всё, что на неё ссылается
+func destroyBoard(ctx context.Context, tx *sql.Tx, boardID int) error {
+	statements := []string{
+		`DELETE FROM board_elements WHERE board_id = $1`,
+		`DELETE FROM board_element_tombstones WHERE board_id = $1`,
+		`DELETE FROM board_revisions WHERE board_id = $1`,
+		`DELETE FROM board_operations WHERE board_id = $1`,
+		`DELETE FROM board_export_jobs WHERE board_id = $1`,
+		`DELETE FROM board_thumbnails WHERE board_id = $1`,
+		`DELETE FROM board_permissions WHERE board_id = $1`,
+		`DELETE FROM board_invites WHERE board_id = $1`,
+		`DELETE FROM boards WHERE id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, boardID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashElements переносит элементы доски в корзину: помечает их deleted_at и кладет
+// под остальные элементы, а для ленты изменений оставляет надгробия с версией удаления.
+// rootID - элемент, удаление которого затронуло остальные, 0 - каждый элемент попадает
+// в корзину отдельной записью.
+func trashElements(ctx context.Context, tx *sql.Tx, boardID, rootID int, ids []int64, deletedBy int, version int64) error {
+	var deleter, root *int
+	if deletedBy != 0 {
+		deleter = &deletedBy
+	}
+	if rootID != 0 {
+		root = &rootID
+	}
+	// Отрицательный z_index не пересекается с порядком наложения оставшихся элементов,
+	// прежний сохраняется в trashed_z_index для восстановления
+	query := `WITH trashed AS (
+                  UPDATE board_elements SET deleted_at = NOW(), deleted_by = $3, trash_root_id = COALESCE($4, id), version = $5,
+                                            trashed_z_index = z_index, z_index = -id
+                  WHERE board_id = $1 AND id = ANY($2::int[]) AND deleted_at IS NULL
+                  RETURNING id
+              )
+              INSERT INTO board_element_tombstones (element_id, board_id, version, deleted_at)
+              SELECT id, $1, $5, NOW() FROM trashed`
+	_, err := tx.ExecContext(ctx, query, boardID, pq.Array(ids), deleter, root, version)
+	return err
+}
+
+// destroyTrashedElements окончательно удаляет элементы корзины доски $1, подходящие под условие
+// condition с параметром $2, и возвращает их число
+func destroyTrashedElements(ctx context.Context, tx *sql.Tx, boardID int, condition string, arg interface{}) (int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM board_elements WHERE board_id = $1 AND deleted_at IS NOT NULL AND `+condition, boardID, arg)
+	if err != nil {
+		return 0, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := releaseTrashedRefs(ctx, tx, boardID, ids); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM board_elements WHERE board_id = $1 AND id = ANY($2::int[])`, boardID, pq.Array(ids)); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// releaseTrashedRefs открепляет оставшиеся в корзине элементы от элементов ids,
+// которые удаляются окончательно. Элементы на доске на элементы корзины не ссылаются:
+// при удалении связи разрываются, а при восстановлении отсутствующие ссылки открепляются.
+func releaseTrashedRefs(ctx context.Context, tx *sql.Tx, boardID int, ids []int64) error {
+	query := `UPDATE board_elements
+              SET parent_id = CASE WHEN parent_id = ANY($2::int[]) THEN NULL ELSE parent_id END,
+                  source_element_id = CASE WHEN source_element_id = ANY($2::int[]) THEN NULL ELSE source_element_id END,
+                  target_element_id = CASE WHEN target_element_id = ANY($2::int[]) THEN NULL ELSE target_element_id END
+              WHERE board_id = $1 AND deleted_at IS NOT NULL AND NOT (id = ANY($2::int[]))
+                AND (parent_id = ANY($2::int[]) OR source_element_id = ANY($2::int[]) OR target_element_id = ANY($2::int[]))`
+	_, err := tx.ExecContext(ctx, query, boardID, pq.Array(ids))
+	return err
+}
+
+// checkTrashedElement проверяет, что элемент есть в корзине доски отдельной записью
+// и, если deletedBy не 0, удален этим пользователем. Иначе возвращает ErrNotFound.
+func checkTrashedElement(ctx context.Context, tx *sql.Tx, boardID, elementID, deletedBy int) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM board_elements
+                             WHERE board_id = $1 AND id = $2 AND trash_root_id = id AND deleted_at IS NOT NULL
+                               AND ($3 = 0 OR deleted_by = $3))`
+	if err := tx.QueryRowContext(ctx, query, boardID, elementID, deletedBy).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// lockBoard блокирует доску не из корзины до конца транзакции, не меняя её версию
+func lockBoard(ctx context.Context, tx *sql.Tx, boardID int) error {
+	var id int
+	err := tx.QueryRowContext(ctx, `SELECT id FROM boards WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, boardID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// extraScanner дочитывает колонки, которые идут в запросе после колонок элемента
+type extraScanner struct {
+	row   scanner
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
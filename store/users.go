@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"micromiro/models"
+)
+
+type userStore struct {
+	db *sql.DB
+}
+
+// NewUserStore создает хранилище пользователей поверх общего пула соединений
+func NewUserStore(db *sql.DB) UserStore {
+	return &userStore{db: db}
+}
+
+func (s *userStore) CreateUser(ctx context.Context, user *models.User) error {
+	query := `INSERT INTO users (username, email, password, role_id, created_at, updated_at)
+              VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Password, user.RoleID, user.CreatedAt, user.UpdatedAt).Scan(&user.ID)
+}
+
+func (s *userStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, username, email, password, role_id FROM users WHERE email = $1`
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.RoleID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindUser ищет пользователя по email или по имени. Пустые значения не совпадают ни с кем.
+func (s *userStore) FindUser(ctx context.Context, email, username string) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, username, email FROM users WHERE (email = $1 AND $1 <> '') OR (username = $2 AND $2 <> '') LIMIT 1`
+	err := s.db.QueryRowContext(ctx, query, email, username).Scan(&user.ID, &user.Username, &user.Email)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByID возвращает пользователя без хеша пароля
+func (s *userStore) GetUserByID(ctx context.Context, userID int) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, username, email, role_id FROM users WHERE id = $1`
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.Username, &user.Email, &user.RoleID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}